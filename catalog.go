@@ -0,0 +1,154 @@
+// catalog.go manages the user's station catalog, a JSON file in the config
+// directory that extends (or overrides) the built-in stations.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// builtinStations is a copy of the stations compiled into the binary.
+var builtinStations = append([]Station(nil), stations...)
+
+// catalogModTime is the modification time of the catalog file when it was last loaded.
+var catalogModTime time.Time
+
+// catalogPath returns the path to the user's station catalog.
+func catalogPath() string {
+	return filepath.Join(configDir(), "stations.json")
+}
+
+// readCatalog returns the user's saved stations. A missing file yields none.
+func readCatalog() ([]Station, error) {
+	data, err := os.ReadFile(catalogPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var user []Station
+	if err := json.Unmarshal(data, &user); err != nil {
+		return nil, fmt.Errorf("%s: %v", catalogPath(), err)
+	}
+	return user, nil
+}
+
+// loadCatalog merges the user's catalog into stations. User entries with
+// the same name as a built-in station replace it. Invalid profile settings
+// are clamped or dropped, and reported in the returned error.
+func loadCatalog() error {
+	user, err := readCatalog()
+	if err != nil {
+		return err
+	}
+
+	var problems []error
+	for i, u := range user {
+		p, err := u.Profile.Sanitize()
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: station %s: %w", catalogPath(), u.Name, err))
+			user[i].Profile = p
+		}
+	}
+
+	merged := append([]Station(nil), builtinStations...)
+	for _, u := range user {
+		replaced := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Name, u.Name) {
+				merged[i] = u
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, u)
+		}
+	}
+	stations = merged
+
+	if fi, err := os.Stat(catalogPath()); err == nil {
+		catalogModTime = fi.ModTime()
+	}
+	return errors.Join(problems...)
+}
+
+// reloadCatalog reloads the catalog if the file changed since it was last
+// loaded, so a running daemon picks up stations saved by other commands.
+func reloadCatalog() error {
+	fi, err := os.Stat(catalogPath())
+	if err != nil || fi.ModTime().Equal(catalogModTime) {
+		return nil
+	}
+	return loadCatalog()
+}
+
+// saveStation adds a station to the user's catalog, replacing any existing
+// entry with the same name, and reloads stations.
+func saveStation(s Station) error {
+	user, err := readCatalog()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range user {
+		if strings.EqualFold(user[i].Name, s.Name) {
+			user[i] = s
+			replaced = true
+		}
+	}
+	if !replaced {
+		user = append(user, s)
+	}
+
+	data, err := json.MarshalIndent(user, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(catalogPath(), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return loadCatalog()
+}
+
+// stationSlug turns a free-form title into a station name like "night-drive",
+// adding a numeric suffix if the name is already taken.
+func stationSlug(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+			dash = false
+		case !dash && b.Len() > 0:
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+
+	base := strings.Trim(b.String(), "-")
+	if len(base) > 24 {
+		base = strings.TrimRight(base[:24], "-")
+	}
+	if base == "" {
+		base = "station"
+	}
+
+	name := base
+	for i := 2; findStation(name) != nil; i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name
+}
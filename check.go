@@ -0,0 +1,299 @@
+// check.go implements "chill --check", which probes every station with
+// yt-dlp in parallel and caches the results so skip can avoid dead stations.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	checkJobs    = 4                // stations probed at once
+	checkTimeout = 30 * time.Second // per-station probe timeout
+	healthMaxAge = 24 * time.Hour   // cached results older than this are ignored
+)
+
+// Station health states reported by --check.
+const (
+	healthLive       = "live"
+	healthOffline    = "offline"
+	healthGeoBlocked = "geo-blocked"
+	healthPrivate    = "private"
+	healthError      = "error"
+)
+
+// Health is the result of probing one station.
+type Health struct {
+	Station   string    `json:"station"`
+	Status    string    `json:"status"`
+	LatencyMS int64     `json:"latency_ms"`
+	Formats   []string  `json:"formats,omitempty"` // audio formats, e.g. "opus 160k"
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Dead reports whether the station is known to be unplayable.
+func (h Health) Dead() bool {
+	switch h.Status {
+	case healthOffline, healthGeoBlocked, healthPrivate:
+		return time.Since(h.CheckedAt) < healthMaxAge
+	}
+	return false
+}
+
+// ytFormat is the subset of a yt-dlp format we report on.
+type ytFormat struct {
+	Ext    string  `json:"ext"`
+	ACodec string  `json:"acodec"`
+	VCodec string  `json:"vcodec"`
+	ABR    float64 `json:"abr"`
+}
+
+// ytInfo is the subset of yt-dlp's JSON output we inspect.
+type ytInfo struct {
+	LiveStatus string     `json:"live_status"`
+	Formats    []ytFormat `json:"formats"`
+}
+
+// healthPath returns the path to the cached check results. Like the state
+// directory, each daemon profile has its own.
+func healthPath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	dir = filepath.Join(dir, "chill")
+	if daemonProfile != "" {
+		dir = filepath.Join(dir, "profiles", daemonProfile)
+	}
+	return filepath.Join(dir, "health.json")
+}
+
+// loadHealth returns cached check results keyed by lowercased station name.
+func loadHealth() map[string]Health {
+	health := make(map[string]Health)
+	data, err := os.ReadFile(healthPath())
+	if err != nil {
+		return health
+	}
+
+	var results []Health
+	json.Unmarshal(data, &results)
+	for _, h := range results {
+		health[strings.ToLower(h.Station)] = h
+	}
+	return health
+}
+
+// saveHealth caches check results.
+func saveHealth(results []Health) error {
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(healthPath(), append(data, '\n'), 0644)
+}
+
+// withoutDead drops stations the last check found dead. If that would leave
+// nothing, candidates is returned unchanged.
+func withoutDead(candidates []Station) []Station {
+	health := loadHealth()
+
+	var alive []Station
+	for _, s := range candidates {
+		if h, ok := health[strings.ToLower(s.Name)]; !ok || !h.Dead() {
+			alive = append(alive, s)
+		}
+	}
+	if len(alive) == 0 {
+		return candidates
+	}
+	return alive
+}
+
+// checkStations probes stations concurrently, at most jobs at a time,
+// returning results in catalog order.
+func checkStations(list []Station, jobs int, timeout time.Duration) []Health {
+	results := make([]Health, len(list))
+	sem := make(chan struct{}, jobs)
+
+	var wg sync.WaitGroup
+	for i, s := range list {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			results[i] = probeStation(ctx, s)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+// probeStation checks whether a station is playable and which audio formats
+// it offers.
+func probeStation(ctx context.Context, s Station) (h Health) {
+	h.Station = s.Name
+	start := time.Now()
+	defer func() {
+		h.LatencyMS = time.Since(start).Milliseconds()
+		h.CheckedAt = time.Now()
+	}()
+
+	if s.Path != "" {
+		tracks, err := localTracks(s.Path)
+		if err != nil {
+			h.Status, h.Error = healthOffline, err.Error()
+			return h
+		}
+		h.Status = healthLive
+		h.Formats = []string{fmt.Sprintf("%d local tracks", len(tracks))}
+		return h
+	}
+
+	url := s.URL
+	if s.Channel != "" {
+		live, err := resolveLive(ctx, s.Channel, s.Match)
+		if err != nil && url == "" {
+			h.Status, h.Error = classifyProbeError(err), err.Error()
+			return h
+		}
+		if err == nil {
+			url = live
+		}
+	}
+
+	out, err := ytdlp(ctx, "-J", "--skip-download", "--no-playlist", url)
+	if err != nil {
+		h.Status, h.Error = classifyProbeError(err), err.Error()
+		return h
+	}
+
+	var info ytInfo
+	if err := json.Unmarshal(out, &info); err != nil {
+		h.Status, h.Error = healthError, err.Error()
+		return h
+	}
+
+	switch info.LiveStatus {
+	case "is_upcoming", "was_live", "post_live":
+		h.Status = healthOffline
+	default:
+		h.Status = healthLive
+	}
+	h.Formats = audioFormats(info.Formats)
+	return h
+}
+
+// classifyProbeError maps a yt-dlp error message to a health state.
+func classifyProbeError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return healthError
+	}
+
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "country"), strings.Contains(msg, "geo"):
+		return healthGeoBlocked
+	case strings.Contains(msg, "private"), strings.Contains(msg, "sign in"),
+		strings.Contains(msg, "members-only"):
+		return healthPrivate
+	case strings.Contains(msg, "not live"), strings.Contains(msg, "offline"),
+		strings.Contains(msg, "has ended"), strings.Contains(msg, "will begin"),
+		strings.Contains(msg, "unavailable"), strings.Contains(msg, "removed"):
+		return healthOffline
+	}
+	return healthError
+}
+
+// audioFormats summarizes the distinct audio formats on offer, preferring
+// audio-only formats when there are any.
+func audioFormats(formats []ytFormat) []string {
+	describe := func(audioOnly bool) []string {
+		var out []string
+		seen := make(map[string]bool)
+		for _, f := range formats {
+			if f.ACodec == "" || f.ACodec == "none" {
+				continue
+			}
+			if audioOnly && f.VCodec != "none" {
+				continue
+			}
+			codec, _, _ := strings.Cut(f.ACodec, ".")
+			desc := codec
+			if f.ABR > 0 {
+				desc += fmt.Sprintf(" %.0fk", f.ABR)
+			}
+			if !seen[desc] {
+				seen[desc] = true
+				out = append(out, desc)
+			}
+		}
+		return out
+	}
+
+	out := describe(true)
+	if len(out) == 0 {
+		out = describe(false)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// runCheck implements "chill --check [--json]". It exits with status 1 if
+// any station is dead, so scripts can tell.
+func runCheck(asJSON bool) {
+	if !asJSON {
+		fmt.Printf("%s  checking %d stations...%s\n", dim, len(stations), reset)
+	}
+
+	results := checkStations(stations, checkJobs, checkTimeout)
+	if err := saveHealth(results); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(results)
+	} else {
+		printHealth(results)
+	}
+
+	for _, h := range results {
+		if h.Dead() {
+			os.Exit(1)
+		}
+	}
+}
+
+// printHealth prints check results, one station per line.
+func printHealth(results []Health) {
+	for _, h := range results {
+		mark := purple + "●" + reset
+		if h.Status != healthLive {
+			mark = dim + "○" + reset
+		}
+		latency := time.Duration(h.LatencyMS) * time.Millisecond
+		detail := strings.Join(h.Formats, ", ")
+		if h.Error != "" {
+			detail = truncate(h.Error, 50)
+		}
+		fmt.Printf("  %s %s%-16s%s %-12s %s%6s  %s%s\n",
+			mark, cyan, h.Station, reset, h.Status, dim, latency.Round(100*time.Millisecond), detail, reset)
+	}
+}
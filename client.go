@@ -1,27 +1,47 @@
 // client.go implements the CLI client that communicates with the daemon.
-// It sends commands over a Unix socket and displays responses to the user.
+// It sends commands over a Unix socket, or TLS to a remote daemon, and
+// displays responses to the user.
 
 package main
 
 import (
 	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 )
 
-// sendCommand sends a command to the daemon and returns the response.
+// interactive is set while the REPL runs, where errors are shown rather
+// than fatal.
+var interactive bool
+
+// lastID numbers the requests this client sends.
+var lastID int
+
+// dial connects to the daemon: the remote one given by --remote, otherwise
+// the local one.
+func dial() (net.Conn, error) {
+	if remoteAddr != "" {
+		return dialRemote(remoteAddr)
+	}
+	return dialSocket()
+}
+
+// sendCommand sends one protocol line to the daemon and returns the reply line.
 func sendCommand(cmd string) (string, error) {
-	conn, err := dialSocket()
+	conn, err := dial()
 	if err != nil {
 		return "", err
 	}
 	defer conn.Close()
 
-	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	// resolving a channel station's live stream can take several seconds
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
 
 	_, err = conn.Write([]byte(cmd + "\n"))
 	if err != nil {
@@ -37,54 +57,124 @@ func sendCommand(cmd string) (string, error) {
 	return strings.TrimSpace(response), nil
 }
 
-// ensureDaemon starts the daemon if it's not already running.
-// It waits up to 2 seconds for the daemon to become ready.
+// ensureDaemon starts the daemon if it's not already running, and waits
+// until it is ready for commands.
 func ensureDaemon() error {
 	if isDaemonRunning() {
 		return nil
 	}
 
-	// start daemon in background
-	exe, _ := os.Executable()
-	cmd := exec.Command(exe, "--daemon")
-	cmd.Stdout = nil
-	cmd.Stderr = nil
-	cmd.Stdin = nil
-
-	if err := cmd.Start(); err != nil {
+	if err := secureRuntimeDir(); err != nil {
 		return err
 	}
+	lock, err := lockFile(startLockPath(), true)
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
 
-	// wait for daemon to be ready
-	for i := 0; i < 20; i++ {
-		time.Sleep(100 * time.Millisecond)
-		if isDaemonRunning() {
-			return nil
-		}
+	// another command may have started it while we waited for the lock
+	if isDaemonRunning() {
+		return nil
+	}
+	return startDaemon()
+}
+
+// call sends a JSON protocol request to the daemon. If the daemon rejects
+// it, the response is returned along with its *ProtoError.
+func call(req Request) (*Response, error) {
+	req = numbered(req)
+	b, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	line, err := sendCommand(string(b))
+	if err != nil {
+		return nil, err
 	}
 
-	return fmt.Errorf("daemon failed to start")
+	var resp Response
+	if err := json.Unmarshal([]byte(line), &resp); err != nil {
+		return nil, oldDaemon(line)
+	}
+	if string(resp.ID) != string(req.ID) {
+		return nil, fmt.Errorf("response %s does not match request %s", resp.ID, req.ID)
+	}
+	if resp.Error != nil {
+		return &resp, resp.Error
+	}
+	return &resp, nil
+}
+
+// numbered stamps req with the protocol version and the next request ID.
+func numbered(req Request) Request {
+	lastID++
+	req.V = protocolVersion
+	req.ID = json.RawMessage(strconv.Itoa(lastID))
+	return req
+}
+
+// oldDaemon is the error for a reply that isn't a JSON response, which
+// means the daemon predates the JSON protocol.
+func oldDaemon(reply string) error {
+	return fmt.Errorf("daemon speaks an older protocol (%s); restart it with chill --shutdown", reply)
 }
 
-// clientPlay starts playing the specified station via the daemon.
+// fail reports a client error: protocol errors by their message, anything
+// else prefixed with "error:". Outside the REPL it exits.
+func fail(err error) {
+	msg := "error: " + err.Error()
+	var pe *ProtoError
+	if errors.As(err, &pe) {
+		msg = pe.Message
+	}
+
+	if interactive {
+		fmt.Printf("%s%s%s\n", dim, msg, reset)
+		return
+	}
+	fmt.Fprintln(os.Stderr, msg)
+	os.Exit(1)
+}
+
+// clientPlay starts playing the specified station via the daemon. The
+// station may also be "tag:<name>", or a URL or @channel followed by a title.
 func clientPlay(station string) {
 	if err := ensureDaemon(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		fail(err)
+		return
 	}
 
-	cmd := "play"
-	if station != "" {
-		cmd += " " + station
+	req := parseLegacy("play " + station)
+	resp, err := call(req)
+	if err != nil {
+		fail(err)
+		return
 	}
 
-	resp, err := sendCommand(cmd)
+	fmt.Printf("%s♪ %s%s\n", pink, resp.Message, reset)
+}
+
+// clientResume resumes the last station the daemon played, which it
+// remembers across restarts.
+func clientResume() {
+	if err := ensureDaemon(); err != nil {
+		fail(err)
+		return
+	}
+
+	resp, err := call(Request{Action: "restore"})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		fail(err)
+		return
 	}
 
-	fmt.Printf("%s♪ %s%s\n", pink, resp, reset)
+	fmt.Printf("%s♪ %s%s\n", pink, resp.Message, reset)
+}
+
+// clientPlayURL plays an arbitrary stream URL via the daemon, shown with the given title.
+func clientPlayURL(url, title string) {
+	clientPlay(strings.TrimSpace(url + " " + title))
 }
 
 // clientStatus displays the current playback status.
@@ -94,20 +184,24 @@ func clientStatus() {
 		return
 	}
 
-	resp, err := sendCommand("status")
+	resp, err := call(Request{Action: "status"})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		fail(err)
+		return
 	}
 
 	var s Status
-	if err := json.Unmarshal([]byte(resp), &s); err != nil {
-		fmt.Println(resp)
+	if err := json.Unmarshal(resp.Result, &s); err != nil {
+		fail(err)
 		return
 	}
 
 	if !s.Playing && !s.Paused {
-		fmt.Println(dim + "idle" + reset)
+		if s.Reconnecting != "" {
+			fmt.Println(dim + "reconnecting to " + s.Reconnecting + reset)
+		} else {
+			fmt.Println(dim + "idle" + reset)
+		}
 		return
 	}
 
@@ -116,57 +210,289 @@ func clientStatus() {
 		state = dim + "⏸" + reset
 	}
 
+	info := s.Station + " │ " + s.Uptime
+	if s.Tag != "" {
+		info += " │ #" + s.Tag
+	}
+	if s.Volume != 0 {
+		info += fmt.Sprintf(" │ vol %d", s.Volume)
+	}
+	if s.EQ != "" {
+		info += " │ eq " + s.EQ
+	}
+	if s.Muted {
+		info += " │ muted"
+	}
+	switch s.Rating {
+	case 1:
+		info += " │ ↑"
+	case -1:
+		info += " │ ↓"
+	}
+
 	fmt.Printf("%s %s%s%s\n", state, pink, s.Desc, reset)
-	fmt.Printf("  %s%s │ %s%s\n", dim, s.Station, s.Uptime, reset)
+	if s.Track != "" && s.Track != s.Desc {
+		fmt.Printf("  %s♫ %s%s\n", cyan, s.Track, reset)
+	}
+	fmt.Printf("  %s%s%s\n", dim, info, reset)
+	printQueue(s.Queue)
+}
+
+// printQueue displays the running queue segment and what follows it.
+func printQueue(q *QueueStatus) {
+	if q == nil {
+		return
+	}
+	if q.Current != "" {
+		left := ""
+		if q.Remaining != "" {
+			left = "  " + q.Remaining + " left"
+		}
+		fmt.Printf("  %s▸ %s%s%s%s\n", purple, cyan, q.Current, dim+left, reset)
+	}
+	for _, seg := range q.Next {
+		fmt.Printf("    %s%s%s\n", dim, seg, reset)
+	}
+}
+
+// clientQueue runs a queue command: "set <spec>", "add <spec>", "show" or "clear".
+func clientQueue(args []string) {
+	if len(args) == 0 {
+		args = []string{"show"}
+	}
+	op := args[0]
+	if op == "show" && !isDaemonRunning() {
+		fmt.Println(dim + "no queue" + reset)
+		return
+	}
+	if err := ensureDaemon(); err != nil {
+		fail(err)
+		return
+	}
+
+	resp, err := call(Request{Action: "queue", Op: op, Queue: strings.Join(args[1:], " ")})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	if op != "show" {
+		fmt.Printf("%s♪ %s%s\n", pink, resp.Message, reset)
+		return
+	}
+	var q *QueueStatus
+	if err := json.Unmarshal(resp.Result, &q); err != nil {
+		fail(err)
+		return
+	}
+	if q == nil {
+		fmt.Println(dim + "no queue" + reset)
+		return
+	}
+	printQueue(q)
 }
 
 // clientToggle pauses if playing, resumes if paused, or starts playing if stopped.
 func clientToggle() {
 	if !isDaemonRunning() {
-		clientPlay("lofi-girl")
+		clientPlay("")
 		return
 	}
 
-	resp, err := sendCommand("toggle")
+	resp, err := call(Request{Action: "toggle"})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		fail(err)
+		return
 	}
 
-	if resp == "paused" {
+	switch resp.Message {
+	case "paused":
 		fmt.Printf("%s⏸ paused%s\n", dim, reset)
-	} else {
+	case "resumed":
 		fmt.Printf("%s▶ resumed%s\n", purple, reset)
+	default:
+		fmt.Printf("%s♪ %s%s\n", pink, resp.Message, reset)
+	}
+}
+
+// clientSkip skips to a random different station, limited to stations
+// with the given tag if it is non-empty.
+func clientSkip(tag string) {
+	if err := ensureDaemon(); err != nil {
+		fail(err)
+		return
+	}
+
+	resp, err := call(Request{Action: "skip", Tag: strings.TrimPrefix(tag, "tag:")})
+	if err != nil {
+		fail(err)
+		return
 	}
+
+	fmt.Printf("%s♪ %s%s\n", pink, resp.Message, reset)
 }
 
-// clientSkip skips to a random different station.
-func clientSkip() {
+// clientNavigate runs "next" or "prev" to step through stations.
+func clientNavigate(action string) {
 	if err := ensureDaemon(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		fail(err)
+		return
 	}
 
-	resp, err := sendCommand("skip")
+	resp, err := call(Request{Action: action})
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		fail(err)
+		return
 	}
 
-	fmt.Printf("%s♪ %s%s\n", pink, resp, reset)
+	fmt.Printf("%s♪ %s%s\n", pink, resp.Message, reset)
 }
 
-// clientStop stops playback and terminates the daemon.
+// clientRate gives the current station (or the named one) a thumbs up or
+// down, which makes skip pick it more or less often.
+func clientRate(rating, station string) {
+	if !isDaemonRunning() {
+		fmt.Println(dim + "not running" + reset)
+		return
+	}
+
+	resp, err := call(Request{Action: "rate", Rating: rating, Station: station})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	fmt.Println(dim + resp.Message + reset)
+}
+
+// clientSet overrides a playback setting (volume, eq, format or mute) for
+// the rest of the daemon session.
+func clientSet(key, value string) {
+	if err := ensureDaemon(); err != nil {
+		fail(err)
+		return
+	}
+
+	resp, err := call(Request{Action: "set", Setting: key, Value: value})
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	fmt.Println(dim + resp.Message + reset)
+}
+
+// clientStop stops playback. The daemon stays up, idle.
 func clientStop() {
 	if !isDaemonRunning() {
 		fmt.Println(dim + "not running" + reset)
 		return
 	}
 
-	_, err := sendCommand("stop")
+	resp, err := call(Request{Action: "stop"})
 	if err != nil {
-		// daemon exited, that's fine
+		fail(err)
+		return
+	}
+
+	fmt.Println(dim + "■ " + resp.Message + reset)
+}
+
+// clientShutdown stops playback and shuts the daemon down.
+func clientShutdown() {
+	if !isDaemonRunning() {
+		fmt.Println(dim + "not running" + reset)
+		return
+	}
+
+	if _, err := call(Request{Action: "shutdown"}); err != nil {
+		if remoteAddr != "" {
+			fail(err)
+			return
+		}
+		// daemons from before the JSON protocol shut down on "quit"
+		sendCommand("quit")
+	}
+	if remoteAddr == "" {
+		waitForExit()
 	}
 
 	fmt.Println(dim + "~ stay chill ~" + reset)
 }
+
+// clientWatch subscribes to daemon events and prints them until the daemon
+// goes away. With asJSON each event is printed as the raw JSON line, for
+// status bars and scripts.
+func clientWatch(asJSON bool) {
+	if err := ensureDaemon(); err != nil {
+		fail(err)
+		return
+	}
+
+	conn, err := dial()
+	if err != nil {
+		fail(err)
+		return
+	}
+	defer conn.Close()
+
+	req, _ := json.Marshal(numbered(Request{Action: "subscribe"}))
+	if _, err := conn.Write(append(req, '\n')); err != nil {
+		fail(err)
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(nil, 1<<20)
+	if !scanner.Scan() {
+		fail(errors.New("daemon closed the connection"))
+		return
+	}
+	var resp Response
+	if err := json.Unmarshal(scanner.Bytes(), &resp); err != nil {
+		fail(oldDaemon(scanner.Text()))
+		return
+	}
+	if resp.Error != nil {
+		fail(resp.Error)
+		return
+	}
+	if !asJSON {
+		fmt.Println(dim + "watching, ctrl+c to stop" + reset)
+	}
+
+	for scanner.Scan() {
+		if asJSON {
+			fmt.Println(scanner.Text())
+			continue
+		}
+		var ev Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		printEvent(ev)
+	}
+
+	if !asJSON {
+		fmt.Println(dim + "daemon stopped" + reset)
+	}
+}
+
+// printEvent displays one daemon event with its time.
+func printEvent(ev Event) {
+	when := dim + ev.Time.Local().Format("15:04:05") + reset
+	switch ev.Type {
+	case evStation:
+		fmt.Printf("%s %s♪ %s%s\n", when, pink, ev.Status.Desc, reset)
+	case evTrack:
+		fmt.Printf("%s %s♫ %s%s\n", when, cyan, ev.Message, reset)
+	case evPause:
+		fmt.Printf("%s %s⏸ paused%s\n", when, dim, reset)
+	case evResume:
+		fmt.Printf("%s %s▶ resumed%s\n", when, purple, reset)
+	case evError:
+		fmt.Printf("%s %s✗ %s%s\n", when, pink, ev.Message, reset)
+	default:
+		fmt.Printf("%s %s%s%s\n", when, dim, ev.Message, reset)
+	}
+}
@@ -0,0 +1,158 @@
+// config.go loads user configuration from the chill config directory.
+// Every setting has a sensible default so the file is entirely optional.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// defaultRadioAPI is the Radio Browser mirror used when none is configured.
+const defaultRadioAPI = "https://de1.api.radio-browser.info"
+
+// Config holds user settings read from config.json.
+type Config struct {
+	RadioAPI string `json:"radio_api,omitempty"` // Radio Browser-compatible directory base URL
+	HTTP     string `json:"http,omitempty"`      // loopback address for the daemon's HTTP API, e.g. "localhost:7878"
+	Listen   string `json:"listen,omitempty"`    // TCP address for remote control over TLS, e.g. ":7879"
+
+	AudioDevice string `json:"audio_device,omitempty"` // mpv audio device, e.g. "pulse/bluez_sink.headphones"
+	LogLevel    string `json:"log_level,omitempty"`    // daemon log level: debug, info (default), warn or error
+	Resume      bool   `json:"resume,omitempty"`       // plain chill resumes the last station instead of lofi-girl
+	IdleTimeout string `json:"idle_timeout,omitempty"` // how long an idle daemon waits before exiting, e.g. "1h"; "0" never exits
+
+	// Profiles holds the http, listen and audio_device settings of named
+	// daemon profiles. Those settings at the top level apply to the
+	// default daemon only, so profiles don't fight over addresses.
+	Profiles map[string]*Config `json:"profiles,omitempty"`
+}
+
+// configDir returns the directory holding chill's config and station catalog.
+func configDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "chill")
+}
+
+// stateDir returns the directory for state the daemon keeps between runs,
+// such as play history and ratings. Each daemon profile has its own.
+func stateDir() string {
+	dir := baseStateDir()
+	if daemonProfile != "" {
+		dir = filepath.Join(dir, "profiles", daemonProfile)
+	}
+	return dir
+}
+
+// baseStateDir returns the default daemon's state directory.
+func baseStateDir() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "chill")
+	}
+	if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+		return configDir()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "chill")
+	}
+	return filepath.Join(home, ".local", "state", "chill")
+}
+
+// writeFileAtomic writes data to path via a temp file and rename, so a
+// crash never leaves a truncated file behind. Parent directories are created.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadConfig reads config.json, applying defaults and environment overrides.
+// A missing file is not an error.
+func loadConfig() (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(filepath.Join(configDir(), "config.json"))
+	if err == nil {
+		err = json.Unmarshal(data, cfg)
+	} else if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	if daemonProfile != "" {
+		cfg = cfg.forProfile(daemonProfile)
+	}
+
+	return cfg.withDefaults(), err
+}
+
+// forProfile returns the settings for a named daemon profile: the shared
+// settings plus the profile's own listeners and audio device.
+func (c *Config) forProfile(name string) *Config {
+	p := &Config{RadioAPI: c.RadioAPI, LogLevel: c.LogLevel, Resume: c.Resume, IdleTimeout: c.IdleTimeout}
+	if own := c.Profiles[name]; own != nil {
+		p.HTTP, p.Listen, p.AudioDevice = own.HTTP, own.Listen, own.AudioDevice
+	}
+	return p
+}
+
+// idleTimeout returns the parsed idle_timeout, defaultIdleTimeout if unset.
+// Zero means never exit.
+func (c *Config) idleTimeout() (time.Duration, error) {
+	if c.IdleTimeout == "" {
+		return defaultIdleTimeout, nil
+	}
+	d, err := time.ParseDuration(c.IdleTimeout)
+	if err != nil || d < 0 {
+		return defaultIdleTimeout, fmt.Errorf("idle_timeout: invalid duration %q", c.IdleTimeout)
+	}
+	return d, nil
+}
+
+// withDefaults fills unset fields and applies CHILL_* environment overrides.
+// Like the top-level settings, CHILL_HTTP, CHILL_LISTEN and
+// CHILL_AUDIO_DEVICE only apply to the default daemon: two daemons can't
+// share a listener, and profiles exist to use different devices.
+func (c *Config) withDefaults() *Config {
+	if v := os.Getenv("CHILL_RADIO_API"); v != "" {
+		c.RadioAPI = v
+	}
+	if daemonProfile == "" {
+		if v := os.Getenv("CHILL_HTTP"); v != "" {
+			c.HTTP = v
+		}
+		if v := os.Getenv("CHILL_LISTEN"); v != "" {
+			c.Listen = v
+		}
+		if v := os.Getenv("CHILL_AUDIO_DEVICE"); v != "" {
+			c.AudioDevice = v
+		}
+	}
+	if v, err := strconv.ParseBool(os.Getenv("CHILL_RESUME")); err == nil {
+		c.Resume = v
+	}
+	if v := os.Getenv("CHILL_IDLE_TIMEOUT"); v != "" {
+		c.IdleTimeout = v
+	}
+	if v := os.Getenv("CHILL_LOG_LEVEL"); v != "" {
+		c.LogLevel = v
+	}
+	if c.RadioAPI == "" {
+		c.RadioAPI = defaultRadioAPI
+	}
+	return c
+}
@@ -5,12 +5,16 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,30 +23,131 @@ import (
 // Daemon manages the mpv subprocess and handles client commands.
 // It maintains playback state and communicates over a Unix socket.
 type Daemon struct {
-	mu        sync.Mutex   // protects all fields
-	cmd       *exec.Cmd    // mpv process
-	station   *Station     // currently playing station
-	paused    bool         // whether playback is paused
-	startedAt time.Time    // when current station started
-	listener  net.Listener // Unix socket listener
+	mu        sync.Mutex          // protects all fields
+	cmd       *exec.Cmd           // mpv process
+	done      chan struct{}       // closed once cmd has been reaped
+	station   *Station            // currently playing station
+	paused    bool                // whether playback is paused
+	startedAt time.Time           // when current station started
+	listener  net.Listener        // Unix socket listener
+	resolved  map[string]string   // channel station name -> resolved live URL
+	retries   int                 // consecutive restarts of a channel station
+	reconnect *Station            // channel station waiting to be restarted, if any
+	playGen   int                 // bumped when playback starts or stops
+	tag       string              // tag that skip picks from, set by "play tag:x"
+	shuffle   *Shuffle            // ratings and play history for skip
+	mpv       *mpvConn            // IPC connection to cmd, once established
+	track     string              // title of the track mpv is playing
+	session   Profile             // overrides of station profiles set by "set"
+	muted     bool                // audio muted with "set mute"
+	back      []*Station          // previously played stations for prev, most recent last
+	queue     []Segment           // upcoming queue segments
+	segment   *Segment            // running queue segment, if any
+	segEnds   time.Time           // when the running segment ends
+	segTimer  *time.Timer         // advances the queue when the segment ends
+	queueGen  int                 // invalidates timers of replaced segments
+	subs      map[chan Event]bool // event subscribers, true for clients
+	tracks    []TrackPlay         // tracks heard this session, oldest first
+	activeAt  time.Time           // when the daemon was last used, for the idle timeout
+
+	httpListener      net.Listener // HTTP API listener, if enabled
+	remoteListener    net.Listener // TLS listener for remote clients, if enabled
+	remoteFingerprint string       // SHA-256 of the remote listener's certificate
+	audioDevice       string       // mpv audio device, if configured
+	lock              *os.File     // daemon lock, held until exit
+
+	last       PlaybackState // playback state as last saved
+	resumeLast bool          // play with no station resumes the last one
+}
+
+// backLimit bounds how many stations prev can return through.
+const backLimit = 20
+
+// trackLimit bounds how many tracks the daemon remembers for history.
+const trackLimit = 50
+
+// TrackPlay is a track heard during this daemon session.
+type TrackPlay struct {
+	Title   string    `json:"title"`
+	Station string    `json:"station"`
+	At      time.Time `json:"at"`
 }
 
 // Status represents the current playback state, serialized as JSON for clients.
 type Status struct {
-	Playing bool   `json:"playing"`          // true if actively playing
-	Paused  bool   `json:"paused"`           // true if paused
+	Playing bool   `json:"playing"`           // true if actively playing
+	Paused  bool   `json:"paused"`            // true if paused
 	Station string `json:"station,omitempty"` // station name
 	Desc    string `json:"desc,omitempty"`    // station description
 	Uptime  string `json:"uptime,omitempty"`  // how long current station has been playing
+	Tag     string `json:"tag,omitempty"`     // tag that skip picks from
+	Rating  int    `json:"rating,omitempty"`  // 1 thumbs up, -1 thumbs down
+	Track   string `json:"track,omitempty"`   // current track or stream title
+	Volume  int    `json:"volume,omitempty"`  // volume from the station profile or session
+	EQ      string `json:"eq,omitempty"`      // EQ preset from the station profile or session
+	Muted   bool   `json:"muted,omitempty"`   // audio muted for the session
+
+	Reconnecting string `json:"reconnecting,omitempty"` // channel station about to be restarted, when idle
+
+	Queue *QueueStatus `json:"queue,omitempty"` // station queue, if one is set
 }
 
 // Start initializes the daemon and begins listening for client connections.
 func (d *Daemon) Start() error {
+	lock, err := lockDaemon()
+	if err != nil {
+		return err
+	}
+	d.lock = lock
+
 	ln, err := listenSocket()
 	if err != nil {
 		return err
 	}
 	d.listener = ln
+	d.shuffle = loadShuffle()
+	d.last = loadState()
+	d.session = d.last.Session
+	d.muted = d.last.Muted
+
+	cfg, err := loadConfig()
+	if err := initLog(cfg.LogLevel); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: log: %v\n", err)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: config: %v\n", err)
+		slog.Warn("bad config", "err", err)
+	}
+	d.audioDevice = cfg.AudioDevice
+	d.resumeLast = cfg.Resume
+	if cfg.HTTP != "" {
+		if err := d.listenHTTP(cfg.HTTP); err != nil {
+			ln.Close()
+			cleanupSocket()
+			return err
+		}
+	}
+	if cfg.Listen != "" {
+		if err := d.listenRemote(cfg.Listen); err != nil {
+			ln.Close()
+			if d.httpListener != nil {
+				d.httpListener.Close()
+			}
+			cleanupSocket()
+			return err
+		}
+	}
+	d.startMPRIS()
+
+	timeout, err := cfg.idleTimeout()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: config: %v\n", err)
+		slog.Warn("bad config", "err", err)
+	}
+	if timeout > 0 {
+		go d.exitWhenIdle(timeout)
+	}
+	slog.Info("daemon started", "pid", os.Getpid(), "profile", profileLabel(), "socket", socketPath())
 
 	go func() {
 		for {
@@ -60,70 +165,268 @@ func (d *Daemon) Start() error {
 func (d *Daemon) handle(conn net.Conn) {
 	defer conn.Close()
 	reader := bufio.NewReader(conn)
+	if err := checkPeer(conn, reader); err != nil {
+		slog.Warn("socket client rejected", "err", err)
+		return
+	}
+	d.serve(conn, reader)
+}
 
+// serve runs requests read from conn until it closes.
+func (d *Daemon) serve(conn net.Conn, reader *bufio.Reader) {
 	for {
 		line, err := reader.ReadString('\n')
 		if err != nil {
 			return
 		}
 
-		cmd := strings.TrimSpace(line)
-		parts := strings.SplitN(cmd, " ", 2)
-		action := parts[0]
-		arg := ""
-		if len(parts) > 1 {
-			arg = parts[1]
+		req, legacy, err := decodeRequest(strings.TrimSpace(line))
+		if err == nil && req.Action == "subscribe" {
+			d.serveEvents(conn, req, legacy)
+			return
 		}
 
-		response := d.execute(action, arg)
-		conn.Write([]byte(response + "\n"))
+		var result any
+		var msg string
+		if err == nil {
+			result, msg, err = d.execute(req)
+		}
+		conn.Write([]byte(encodeResponse(req, legacy, result, msg, err) + "\n"))
 
-		if action == "stop" || action == "quit" {
-			d.listener.Close()
-			cleanupSocket()
-			os.Exit(0)
+		if err == nil && (req.Action == "shutdown" || req.Action == "quit") {
+			d.shutdown("shutdown requested by client")
 		}
 	}
 }
 
-func (d *Daemon) execute(action, arg string) string {
+// shutdown stops playback, removes the socket and exits, logging why.
+// Every way the daemon exits goes through here.
+func (d *Daemon) shutdown(reason string) {
+	d.mu.Lock() // held until exit so no other request runs
+	d.exit(reason)
+}
+
+// exit is shutdown with d.mu already held.
+func (d *Daemon) exit(reason string) {
+	slog.Info("shutting down", "reason", reason)
+	d.saveState() // before stopping playback, so a paused station stays paused
+	d.clearQueue()
+	d.kill()
+	d.listener.Close()
+	if d.httpListener != nil {
+		d.httpListener.Close()
+	}
+	if d.remoteListener != nil {
+		d.remoteListener.Close()
+	}
+	cleanupSocket()
+	os.Remove(mpvSocketPath()) // left behind when mpv is killed
+	os.Remove(playlistPath())
+	os.Exit(0)
+}
+
+// execute runs a request, returning its structured result and a short
+// human-readable message. Actions that change playback, and status,
+// return a Status; list returns the stations, history the recent tracks,
+// rate a RateResult and "queue show" a *QueueStatus. Errors are *ProtoError.
+func (d *Daemon) execute(req Request) (any, string, error) {
 	d.mu.Lock()
 	defer d.mu.Unlock()
+	if req.Action != "status" {
+		d.touch() // polling status doesn't keep the daemon up
+	}
 
-	switch action {
-	case "play":
-		return d.play(arg)
+	var msg string
+	var err error
+	switch req.Action {
+	case "play", "skip", "next", "prev", "restore":
+		msg, err = d.navigate(req)
 	case "pause":
-		return d.pause()
+		msg, err = d.pause()
 	case "resume":
-		return d.resume()
+		msg, err = d.resume()
 	case "toggle":
-		if d.paused {
-			return d.resume()
+		switch {
+		case d.cmd == nil:
+			msg, err = d.navigate(Request{Action: "play"}) // stopped: start playing
+		case d.paused:
+			msg, err = d.resume()
+		default:
+			msg, err = d.pause()
 		}
-		return d.pause()
-	case "stop", "quit":
+	case "stop":
+		d.clearQueue()
 		d.kill()
-		return "stopped"
-	case "skip":
-		return d.skip()
+		msg = "stopped"
+		d.emit(evStop, msg)
+	case "shutdown", "quit":
+		msg = "shutting down"
+	case "queue":
+		if req.Op == "show" {
+			return d.queueStatus(), "", nil
+		}
+		msg, err = d.queueCmd(req.Op, req.Queue)
 	case "status":
-		return d.status()
 	case "list":
-		return d.listStations()
+		return stations, "", nil
+	case "history":
+		return d.history(), "", nil
+	case "rate":
+		return d.rate(req.Rating, req.Station)
+	case "set":
+		msg, err = d.set(req.Setting, req.Value)
 	default:
-		return "unknown command"
+		return nil, "", protoError(errUnknownAction, "unknown command")
 	}
+
+	if err != nil {
+		return nil, "", err
+	}
+	d.saveState()
+	return d.status(), msg, nil
 }
 
-func (d *Daemon) play(name string) string {
+// navigate runs a request that picks what to play by hand. Switching
+// stations that way takes over from the queue, which is dropped.
+func (d *Daemon) navigate(req Request) (string, error) {
+	gen := d.playGen
+	var msg string
+	var err error
+	switch req.Action {
+	case "play":
+		if req.Tag != "" {
+			msg, err = d.skip(req.Tag)
+		} else {
+			msg, err = d.play(req.Station, req.Title)
+		}
+	case "skip":
+		msg, err = d.skip(req.Tag)
+	case "next":
+		msg, err = d.next()
+	case "prev":
+		msg, err = d.prev()
+	case "restore":
+		msg, err = d.restore()
+	}
+	if err == nil && d.playGen != gen {
+		d.clearQueue()
+	}
+	return msg, err
+}
+
+// play starts the named station, a stream URL or an @channel. title names
+// an uncatalogued URL or channel.
+func (d *Daemon) play(name, title string) (string, error) {
 	if name == "" {
 		name = "lofi-girl"
+		if d.resumeLast && d.last.Station != "" {
+			name, title = d.last.Station, d.last.Title
+		}
+	}
+	if tag, ok := strings.CutPrefix(name, "tag:"); ok {
+		return d.skip(tag)
 	}
 
-	station := findStation(name)
-	if station == nil {
-		return "unknown station: " + name
+	var station *Station
+	if isURL(name) || strings.HasPrefix(name, "@") {
+		station = adhocStation(name, title)
+	} else {
+		if err := reloadCatalog(); err != nil {
+			slog.Warn("station catalog", "err", err)
+		}
+		var suggestions []Station
+		if station, suggestions = matchStation(name); station == nil {
+			return "", unknownStationError(name, suggestions)
+		}
+	}
+
+	d.tag = ""
+	return d.switchTo(station)
+}
+
+// unknownStationError returns an unknown_station error listing suggestions.
+func unknownStationError(name string, suggestions []Station) *ProtoError {
+	err := protoError(errUnknownStation, unknownStation(name, suggestions))
+	for _, s := range suggestions {
+		err.Suggestions = append(err.Suggestions, s.Name)
+	}
+	return err
+}
+
+// switchTo starts station and, if it started, remembers the station it
+// replaced for prev and records the play for shuffle.
+func (d *Daemon) switchTo(station *Station) (string, error) {
+	prev := d.station
+	d.retries = 0
+	msg, err := d.start(station)
+	if err != nil {
+		return "", err
+	}
+
+	if prev != nil {
+		d.back = append(d.back, prev)
+		if len(d.back) > backLimit {
+			d.back = d.back[len(d.back)-backLimit:]
+		}
+	}
+	if findStation(station.Name) != nil {
+		d.shuffle.Record(station.Name)
+	}
+	return msg, nil
+}
+
+// next plays the station after the current one in catalog order, within
+// the current tag if one is set, wrapping around at the end.
+func (d *Daemon) next() (string, error) {
+	candidates := stationsWithTag(d.tag)
+	if len(candidates) == 0 {
+		return "", protoError(errNoStations, "no stations")
+	}
+
+	i := 0
+	if d.station != nil {
+		for j, s := range candidates {
+			if strings.EqualFold(s.Name, d.station.Name) {
+				i = (j + 1) % len(candidates)
+				break
+			}
+		}
+	}
+	return d.switchTo(&candidates[i])
+}
+
+// prev returns to the previously played station.
+func (d *Daemon) prev() (string, error) {
+	if len(d.back) == 0 {
+		return "", protoError(errNoHistory, "no previous station")
+	}
+
+	station := d.back[len(d.back)-1]
+	d.back = d.back[:len(d.back)-1]
+	d.retries = 0
+	msg, err := d.start(station)
+	if err != nil {
+		d.back = append(d.back, station) // failed; keep it for another try
+	}
+	return msg, err
+}
+
+// start launches mpv for station, replacing whatever is playing.
+func (d *Daemon) start(station *Station) (string, error) {
+	// resolving a channel releases d.mu while yt-dlp runs; if another
+	// request starts or stops playback meanwhile, it wins
+	d.playGen++
+	d.reconnect = nil
+	gen := d.playGen
+	source, err := d.source(station)
+	if d.playGen != gen {
+		return "", errSuperseded
+	}
+	if err != nil {
+		err := protoError(errPlayback, "failed to play "+station.Name+": "+err.Error())
+		slog.Error("resolving stream failed", "station", station.Name, "err", err)
+		d.emit(evError, err.Message)
+		return "", err
 	}
 
 	d.kill()
@@ -131,118 +434,597 @@ func (d *Daemon) play(name string) string {
 	d.paused = false
 	d.startedAt = time.Now()
 
-	d.cmd = exec.Command("mpv",
+	args := []string{
 		"--no-video",
-		"--really-quiet",
-		station.URL,
-	)
-	d.cmd.Stdout = io.Discard
-	d.cmd.Stderr = io.Discard
+		"--msg-level=all=warn", // warnings and errors go to the log
+		"--input-ipc-server=" + mpvSocketPath(),
+	}
+	if d.audioDevice != "" {
+		args = append(args, "--audio-device="+d.audioDevice)
+	}
+	if d.muted {
+		args = append(args, "--mute=yes")
+	}
+	args = append(args, d.profile(station).MPVArgs()...)
+	cmd := exec.Command("mpv", append(args, source...)...)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &lineLogger{level: slog.LevelWarn, msg: "mpv", attrs: []any{"station", station.Name}}
 
-	if err := d.cmd.Start(); err != nil {
-		return "failed to start: " + err.Error()
+	if err := startTied(cmd); err != nil {
+		d.station = nil
+		err := protoError(errPlayback, "failed to start: "+err.Error())
+		slog.Error("starting mpv failed", "station", station.Name, "err", err)
+		d.emit(evError, err.Message)
+		return "", err
 	}
+	d.cmd = cmd
+	d.done = make(chan struct{})
+	go d.wait(cmd, d.done)
+	go d.watch(cmd)
 
-	go func() {
-		d.cmd.Wait()
-	}()
+	slog.Info("playing", "station", station.Name, "pid", cmd.Process.Pid)
+	msg := "playing: " + station.Desc
+	d.emit(evStation, msg)
+	return msg, nil
+}
 
-	return "playing: " + station.Desc
+// profile returns the station's playback profile with session overrides applied.
+func (d *Daemon) profile(station *Station) Profile {
+	return station.Profile.Merge(d.session)
 }
 
-func (d *Daemon) pause() string {
+// set overrides the volume, eq or format of station profiles for the rest
+// of the session. A value of "default" drops the override.
+func (d *Daemon) set(key, value string) (string, error) {
+	usage := protoError(errBadRequest, "usage: set volume|eq|format|mute <value|default>")
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", usage
+	}
+	clear := value == "default"
+
+	if key == "mute" {
+		return d.setMute(value)
+	}
+
+	next := d.session
+	switch key {
+	case "volume":
+		next.Volume = 0
+		if !clear {
+			v, err := strconv.Atoi(value)
+			if err != nil || v < 1 || v > maxVolume {
+				return "", protoError(errBadRequest, fmt.Sprintf("volume must be between 1 and %d", maxVolume))
+			}
+			next.Volume = v
+		}
+	case "eq":
+		next.EQ = ""
+		if !clear {
+			next.EQ = strings.ToLower(value)
+		}
+	case "format":
+		next.Format = ""
+		if !clear {
+			next.Format = value
+		}
+	default:
+		return "", usage
+	}
+	if err := next.Validate(); err != nil {
+		return "", protoError(errBadRequest, err.Error())
+	}
+
+	d.session = next
+	d.applyLive()
+	msg := key + " " + value
+	if key == "volume" {
+		d.emit(evVolume, msg)
+	}
+	return msg, nil
+}
+
+// applyLive pushes the current volume and EQ to a running mpv. Format
+// changes take effect the next time a station starts.
+func (d *Daemon) applyLive() {
+	if d.mpv == nil || d.station == nil || d.paused {
+		return
+	}
+
+	p := d.profile(d.station)
+	volume := p.Volume
+	if volume == 0 {
+		volume = 100
+	}
+	d.mpv.Command("set_property", "volume", volume)
+	d.mpv.Command("af", "remove", eqLabel)
+	if af := eqFilter(p.EQ); af != "" {
+		d.mpv.Command("af", "add", af)
+	}
+	d.mpv.Command("set_property", "mute", d.muted)
+}
+
+// setMute mutes or unmutes playback for the session: on or off, with
+// default meaning off. Like volume, it's announced as a volume event.
+func (d *Daemon) setMute(value string) (string, error) {
+	switch strings.ToLower(value) {
+	case "on", "yes", "true":
+		d.muted = true
+	case "off", "no", "false", "default":
+		d.muted = false
+	default:
+		return "", protoError(errBadRequest, "mute must be on or off")
+	}
+	d.applyLive()
+	msg := "muted"
+	if !d.muted {
+		msg = "unmuted"
+	}
+	d.emit(evVolume, msg)
+	return msg, nil
+}
+
+// source returns the mpv arguments selecting what to play for station:
+// a playlist for local stations, otherwise the stream URL.
+func (d *Daemon) source(station *Station) ([]string, error) {
+	if station.Path != "" {
+		return localPlaylistArgs(station.Path)
+	}
+	url, err := d.streamURL(station)
+	if err != nil {
+		return nil, err
+	}
+	return []string{url}, nil
+}
+
+// watch connects to cmd's IPC server and follows the track title until
+// mpv exits.
+func (d *Daemon) watch(cmd *exec.Cmd) {
+	m, err := dialMPV(mpvSocketPath(), 5*time.Second)
+	if err != nil {
+		return
+	}
+	defer m.Close()
+
+	d.mu.Lock()
+	if d.cmd != cmd {
+		d.mu.Unlock()
+		return
+	}
+	d.mpv = m
+	d.mu.Unlock()
+
+	m.Command("observe_property", 1, "media-title")
+
+	for ev := range m.Events() {
+		if ev.Event != "property-change" || ev.Name != "media-title" {
+			continue
+		}
+		var title string
+		json.Unmarshal(ev.Data, &title)
+
+		d.mu.Lock()
+		if d.cmd == cmd && d.track != title {
+			d.track = title
+			d.recordTrack(title)
+			d.emit(evTrack, title)
+		}
+		d.mu.Unlock()
+	}
+}
+
+// recordTrack adds a track title to the session's history.
+func (d *Daemon) recordTrack(title string) {
+	if title == "" || d.station == nil {
+		return
+	}
+	d.tracks = append(d.tracks, TrackPlay{Title: title, Station: d.station.Name, At: time.Now()})
+	if len(d.tracks) > trackLimit {
+		d.tracks = d.tracks[len(d.tracks)-trackLimit:]
+	}
+}
+
+// history returns the tracks heard this session, most recent first.
+func (d *Daemon) history() []TrackPlay {
+	h := make([]TrackPlay, 0, len(d.tracks))
+	for i := len(d.tracks) - 1; i >= 0; i-- {
+		h = append(h, d.tracks[i])
+	}
+	return h
+}
+
+// streamURL returns the URL mpv should play for station. Channel stations
+// are resolved to their current live stream and cached until it ends.
+// The lock is released while yt-dlp runs so status stays responsive;
+// start abandons the switch if playback changed in the meantime.
+func (d *Daemon) streamURL(station *Station) (string, error) {
+	if station.Channel == "" {
+		return station.URL, nil
+	}
+	if url, ok := d.resolved[station.Name]; ok {
+		return url, nil
+	}
+
+	d.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	url, err := resolveLive(ctx, station.Channel, station.Match)
+	cancel()
+	d.mu.Lock()
+
+	if err != nil {
+		if station.URL != "" {
+			return station.URL, nil
+		}
+		return "", err
+	}
+
+	if d.resolved == nil {
+		d.resolved = make(map[string]string)
+	}
+	d.resolved[station.Name] = url
+	return url, nil
+}
+
+// wait reaps an mpv process. If it exits on its own rather than being
+// killed, the stream has ended: channel stations are re-resolved and
+// restarted with backoff, anything else goes idle.
+func (d *Daemon) wait(cmd *exec.Cmd, done chan struct{}) {
+	cmd.Wait()
+	close(done)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.cmd != cmd {
+		return // killed or replaced
+	}
+	d.touch()
+
+	station := d.station
+	d.cmd = nil
+	d.station = nil
+	d.paused = false
+	d.mpv = nil
+	d.track = ""
+	if station == nil || station.Channel == "" {
+		if station != nil {
+			slog.Warn("stream ended", "station", station.Name, "exit", cmd.ProcessState)
+		}
+		d.emit(evError, "stream ended")
+		return
+	}
+
+	delete(d.resolved, station.Name)
+	if time.Since(d.startedAt) > time.Minute {
+		d.retries = 0
+	}
+	delay := min(5*time.Second<<d.retries, 5*time.Minute)
+	d.retries++
+	slog.Warn("stream ended; reconnecting", "station", station.Name, "exit", cmd.ProcessState, "delay", delay)
+	d.emit(evReconnect, "stream ended; reconnecting to "+station.Name+" in "+delay.String())
+
+	gen := d.playGen
+	d.reconnect = station
+	d.mu.Unlock()
+	time.Sleep(delay)
+	d.mu.Lock()
+
+	if d.playGen != gen {
+		return // user stopped or played something else meanwhile
+	}
+	d.start(station)
+}
+
+// isURL reports whether arg starts with a stream URL rather than a station name.
+func isURL(arg string) bool {
+	return strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")
+}
+
+// adhocStation builds an uncatalogued station for a URL or @channel,
+// described by title if one is given.
+func adhocStation(target, title string) *Station {
+	s := &Station{Name: "url", URL: target, Desc: target}
+	if strings.HasPrefix(target, "@") {
+		s = &Station{Name: target, Channel: target, Desc: target}
+	}
+	if title = strings.TrimSpace(title); title != "" {
+		s.Desc = title
+	}
+	return s
+}
+
+// errSuperseded is returned by a station switch that another request
+// overtook while its stream was being resolved.
+var errSuperseded = protoError(errPlayback, "cancelled by a newer request")
+
+// errNothingPlaying is returned by actions that need something playing.
+var errNothingPlaying = protoError(errNotPlaying, "nothing playing")
+
+func (d *Daemon) pause() (string, error) {
 	if d.cmd == nil || d.cmd.Process == nil {
-		return "nothing playing"
+		return "", errNothingPlaying
 	}
 	if err := pauseProcess(d.cmd.Process); err != nil {
-		return err.Error()
+		return "", err
 	}
 	d.paused = true
-	return "paused"
+	d.emit(evPause, "paused")
+	return "paused", nil
 }
 
-func (d *Daemon) resume() string {
+func (d *Daemon) resume() (string, error) {
 	if d.cmd == nil || d.cmd.Process == nil {
-		return "nothing playing"
+		return "", errNothingPlaying
 	}
 	if err := resumeProcess(d.cmd.Process); err != nil {
-		return err.Error()
+		return "", err
 	}
 	d.paused = false
-	return "resumed"
+	d.applyLive()
+	d.emit(evResume, "resumed")
+	return "resumed", nil
 }
 
-func (d *Daemon) skip() string {
-	if len(stations) == 0 {
-		return "no stations"
+// skip plays a random station other than the current one. A non-empty tag
+// limits the choice to stations with that tag and is remembered for later
+// skips; an empty tag reuses the remembered one.
+func (d *Daemon) skip(tag string) (string, error) {
+	if tag == "" {
+		tag = d.tag
 	}
 
-	// pick a different station
-	var next *Station
-	for {
-		next = &stations[randInt(len(stations))]
-		if d.station == nil || next.Name != d.station.Name {
-			break
+	candidates := withoutDead(stationsWithTag(tag))
+	if len(candidates) == 0 {
+		if tag != "" {
+			return "", protoError(errNoStations, "no stations tagged "+tag)
 		}
-		if len(stations) == 1 {
-			break
+		return "", protoError(errNoStations, "no stations")
+	}
+
+	current := ""
+	if d.station != nil {
+		current = d.station.Name
+	}
+	next := d.shuffle.Pick(candidates, current)
+
+	d.tag = tag
+	return d.switchTo(next)
+}
+
+// queueCmd handles the queue operations set and add, which take a spec
+// like "chillhop 30m, sleep", and clear. Show is answered by execute.
+func (d *Daemon) queueCmd(op, spec string) (string, error) {
+	switch op {
+	case "set":
+		segments, err := parseQueue(spec)
+		if err != nil {
+			return "", protoError(errBadRequest, err.Error())
 		}
+		d.clearQueue()
+		d.queue = segments
+		return d.advanceQueue()
+
+	case "add":
+		segments, err := parseQueue(spec)
+		if err != nil {
+			return "", protoError(errBadRequest, err.Error())
+		}
+		if n := len(d.queue); n > 0 && d.queue[n-1].Duration == 0 {
+			return "", protoError(errBadRequest, d.queue[n-1].Station+" plays indefinitely; give it a duration with queue set")
+		}
+		d.queue = append(d.queue, segments...)
+
+		// an idle queue or an indefinite segment moves on right away
+		if d.segment == nil || d.segment.Duration == 0 {
+			return d.advanceQueue()
+		}
+		var names []string
+		for _, seg := range segments {
+			names = append(names, seg.String())
+		}
+		return "queued " + strings.Join(names, ", "), nil
+
+	case "clear":
+		d.clearQueue()
+		return "queue cleared", nil
 	}
 
-	return d.play(next.Name)
+	return "", protoError(errBadRequest, "usage: queue set|add <station [duration], ...> | show | clear")
+}
+
+// advanceQueue plays the next queue segment, arming a timer to move on
+// when it has a duration.
+func (d *Daemon) advanceQueue() (string, error) {
+	d.clearQueueTimer()
+	if len(d.queue) == 0 {
+		d.segment = nil
+		return "queue finished", nil
+	}
+
+	seg := d.queue[0]
+	d.queue = d.queue[1:]
+	d.segment = &seg
+
+	gen := d.queueGen
+	msg, err := d.play(seg.Station, "")
+	if d.queueGen != gen {
+		return msg, err // the queue was replaced while the station resolved
+	}
+	if err != nil {
+		d.clearQueue()
+		return msg, err
+	}
+	if seg.Duration > 0 {
+		d.segEnds = time.Now().Add(seg.Duration)
+		d.segTimer = time.AfterFunc(seg.Duration, func() {
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if d.queueGen != gen {
+				return
+			}
+			defer d.saveState()
+			if len(d.queue) == 0 {
+				// a timed last segment ends playback
+				d.clearQueue()
+				d.kill()
+				d.emit(evStop, "queue finished")
+				return
+			}
+			d.advanceQueue()
+		})
+	}
+	return msg, err
+}
+
+// clearQueueTimer disarms the running segment's timer.
+func (d *Daemon) clearQueueTimer() {
+	d.queueGen++
+	if d.segTimer != nil {
+		d.segTimer.Stop()
+		d.segTimer = nil
+	}
+}
+
+// clearQueue drops the queue. Whatever is playing keeps playing.
+func (d *Daemon) clearQueue() {
+	d.clearQueueTimer()
+	d.queue = nil
+	d.segment = nil
+}
+
+// queueStatus describes the queue, or returns nil if there is none.
+func (d *Daemon) queueStatus() *QueueStatus {
+	if d.segment == nil && len(d.queue) == 0 {
+		return nil
+	}
+
+	q := &QueueStatus{}
+	if d.segment != nil {
+		q.Current = d.segment.Station
+		if d.segment.Duration > 0 {
+			q.Remaining = shortDuration(time.Until(d.segEnds))
+		}
+	}
+	for _, seg := range d.queue {
+		q.Next = append(q.Next, seg.String())
+	}
+	return q
+}
+
+// rate rates a station up, down or clears its rating. With no name the
+// current station is rated.
+func (d *Daemon) rate(how, name string) (RateResult, string, error) {
+	ratings := map[string]int{"up": 1, "down": -1, "clear": 0}
+	rating, ok := ratings[how]
+	if !ok {
+		return RateResult{}, "", protoError(errBadRequest, "usage: rate up|down|clear [station]")
+	}
+
+	var station *Station
+	if name = strings.TrimSpace(name); name != "" {
+		var suggestions []Station
+		if station, suggestions = matchStation(name); station == nil {
+			return RateResult{}, "", unknownStationError(name, suggestions)
+		}
+	} else if d.station != nil {
+		station = findStation(d.station.Name)
+	}
+	if station == nil {
+		return RateResult{}, "", errNothingPlaying
+	}
+
+	if err := d.shuffle.Rate(station.Name, rating); err != nil {
+		return RateResult{}, "", protoError(errInternal, "failed to save rating: "+err.Error())
+	}
+	result := RateResult{Station: station.Name, Rating: rating}
+	if rating == 0 {
+		return result, "cleared rating for " + station.Name, nil
+	}
+	return result, "rated " + station.Name + " " + how, nil
 }
 
 func (d *Daemon) kill() {
+	d.playGen++ // also cancels a pending reconnect
+	d.touch()
 	if d.cmd != nil && d.cmd.Process != nil {
 		d.cmd.Process.Kill()
-		d.cmd.Wait()
+		d.cmd = nil
+		<-d.done
+	}
+	if d.mpv != nil {
+		d.mpv.Close()
 	}
 	d.cmd = nil
 	d.station = nil
+	d.paused = false
+	d.mpv = nil
+	d.track = ""
+	d.reconnect = nil
 }
 
-func (d *Daemon) status() string {
+func (d *Daemon) status() Status {
 	s := Status{
 		Playing: d.cmd != nil && d.cmd.Process != nil && !d.paused,
 		Paused:  d.paused,
+		Muted:   d.muted,
 	}
 
 	if d.station != nil {
 		s.Station = d.station.Name
 		s.Desc = d.station.Desc
 		s.Uptime = time.Since(d.startedAt).Round(time.Second).String()
+		s.Tag = d.tag
+		s.Rating = d.shuffle.Rating(d.station.Name)
+		s.Track = d.track
+		p := d.profile(d.station)
+		s.Volume = p.Volume
+		s.EQ = p.EQ
 	}
 
-	b, _ := json.Marshal(s)
-	return string(b)
-}
-
-func (d *Daemon) listStations() string {
-	var names []string
-	for _, s := range stations {
-		names = append(names, s.Name)
+	if d.reconnect != nil {
+		s.Reconnecting = d.reconnect.Name
 	}
-	return strings.Join(names, " ")
+	s.Queue = d.queueStatus()
+	return s
 }
 
 // runDaemon starts the daemon process and blocks forever.
 func runDaemon() {
 	d := &Daemon{}
-	if err := d.Start(); err != nil {
+	err := d.Start()
+	signalReady(err)
+	if err != nil {
+		slog.Error("failed to start", "err", err)
 		fmt.Fprintf(os.Stderr, "failed to start daemon: %v\n", err)
 		os.Exit(1)
 	}
 
 	fmt.Println(dim + "chill daemon started" + reset)
+	if daemonProfile != "" {
+		fmt.Println(dim + "profile: " + daemonProfile + reset)
+	}
 	fmt.Println(dim + "socket: " + socketPath() + reset)
+	if d.httpListener != nil {
+		fmt.Println(dim + "http: http://" + d.httpListener.Addr().String() + reset)
+	}
+	if d.remoteListener != nil {
+		fmt.Println(dim + "remote: " + d.remoteListener.Addr().String() + " (sha256 " + d.remoteFingerprint + ")" + reset)
+		fmt.Println(dim + "token: " + tokenPath() + reset)
+	}
 
-	// keep running
-	select {}
+	// run until asked to stop
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, shutdownSignals...)
+	d.shutdown("received " + (<-sig).String())
 }
 
 // isDaemonRunning checks if a daemon is already running by attempting
-// to connect to the socket.
+// to connect to the socket. A remote daemon is assumed to be running, so
+// why it can't be reached is reported by the command itself.
 func isDaemonRunning() bool {
+	if remoteAddr != "" {
+		return true
+	}
 	conn, err := dialSocket()
 	if err != nil {
 		return false
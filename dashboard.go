@@ -0,0 +1,76 @@
+// dashboard.go serves the web dashboard on the daemon's HTTP listener: an
+// embedded single-page UI, kept live by a server-sent event stream of
+// daemon events.
+
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// evStatus is the first event on a dashboard stream, carrying the status
+// as of connecting.
+const evStatus = "status"
+
+// handleDashboard registers the dashboard's page and event stream on mux.
+func (d *Daemon) handleDashboard(mux *http.ServeMux) {
+	web, _ := fs.Sub(webFiles, "web")
+	mux.Handle("GET /", http.FileServerFS(web))
+	mux.HandleFunc("GET /events", d.serveSSE)
+	mux.HandleFunc("GET /history", func(w http.ResponseWriter, r *http.Request) {
+		d.serveHTTP(w, Request{Action: "history"})
+	})
+}
+
+// isDashboardFile reports whether path names one of the dashboard's
+// static files, which need no token.
+func isDashboardFile(path string) bool {
+	name := strings.TrimPrefix(path, "/")
+	if name == "" {
+		return true
+	}
+	fi, err := fs.Stat(webFiles, "web/"+name)
+	return err == nil && !fi.IsDir()
+}
+
+// serveSSE streams daemon events to a browser as server-sent events until
+// it disconnects or falls behind.
+func (d *Daemon) serveSSE(w http.ResponseWriter, r *http.Request) {
+	events, status := d.subscribe(true)
+	defer d.unsubscribe(events)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+
+	send := func(ev Event) error {
+		b, _ := json.Marshal(ev)
+		if _, err := fmt.Fprintf(w, "data: %s\n\n", b); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	if send(Event{V: protocolVersion, Type: evStatus, Time: time.Now(), Status: status}) != nil {
+		return
+	}
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok || send(ev) != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
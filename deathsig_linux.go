@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os/exec"
+	"runtime"
+	"sync"
+	"syscall"
+)
+
+// spawns carries commands to the spawner thread, which starts them.
+var (
+	spawns      = make(chan spawn)
+	spawnerOnce sync.Once
+)
+
+type spawn struct {
+	cmd  *exec.Cmd
+	done chan error
+}
+
+// startTied starts cmd and has the kernel kill it if the daemon dies
+// without cleaning up, so mpv never keeps playing on its own.
+//
+// The kernel sends the signal when the thread that started the child
+// exits, not the process, and Go retires idle threads. So children are
+// started from one goroutine locked to its thread, which never returns.
+func startTied(cmd *exec.Cmd) error {
+	spawnerOnce.Do(func() {
+		go func() {
+			runtime.LockOSThread()
+			for s := range spawns {
+				s.done <- s.cmd.Start()
+			}
+		}()
+	})
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Pdeathsig: syscall.SIGKILL}
+	s := spawn{cmd, make(chan error)}
+	spawns <- s
+	return <-s.done
+}
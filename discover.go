@@ -0,0 +1,138 @@
+// discover.go implements "chill discover", which searches YouTube for
+// currently live streams so rotted station URLs can be replaced.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LiveStream is a live YouTube stream found by discover.
+type LiveStream struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Channel string `json:"channel"`
+	Viewers int    `json:"concurrent_view_count"`
+	Status  string `json:"live_status"`
+}
+
+// URL returns the stream's watch URL.
+func (l LiveStream) URL() string {
+	return "https://www.youtube.com/watch?v=" + l.ID
+}
+
+// discoverLive searches YouTube for live streams matching query, most
+// watched first.
+func discoverLive(ctx context.Context, query string, limit int) ([]LiveStream, error) {
+	if !strings.Contains(strings.ToLower(query), "live") {
+		query += " live"
+	}
+
+	out, err := ytdlp(ctx,
+		"--flat-playlist",
+		"--dump-json",
+		"--match-filter", "live_status=is_live",
+		fmt.Sprintf("ytsearch%d:%s", limit*3, query),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []LiveStream
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var l LiveStream
+		if err := json.Unmarshal(sc.Bytes(), &l); err != nil || l.ID == "" {
+			continue
+		}
+		if l.Status != "" && l.Status != "is_live" {
+			continue
+		}
+		streams = append(streams, l)
+	}
+
+	sort.SliceStable(streams, func(i, j int) bool {
+		return streams[i].Viewers > streams[j].Viewers
+	})
+	if len(streams) > limit {
+		streams = streams[:limit]
+	}
+	return streams, nil
+}
+
+// runDiscover implements "chill discover [query]".
+func runDiscover(args []string) {
+	fs := flag.NewFlagSet("discover", flag.ExitOnError)
+	limit := fs.Int("limit", 10, "maximum number of results")
+	play := fs.Int("play", 0, "play result number n without prompting")
+	save := fs.Int("save", 0, "save result number n without prompting")
+	query := strings.Join(parseInterspersed(fs, args), " ")
+	if query == "" {
+		query = "lofi"
+	}
+
+	fmt.Printf("%s  searching for live %q streams...%s\n", dim, query, reset)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	streams, err := discoverLive(ctx, query, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(streams) == 0 {
+		fmt.Println(dim + "  no live streams found" + reset)
+		return
+	}
+
+	for i, l := range streams {
+		fmt.Printf("  %s%2d%s  %s%s%s\n", purple, i+1, reset, cyan, truncate(l.Title, 60), reset)
+		fmt.Printf("      %s%s · %s watching%s\n", dim, l.Channel, humanCount(l.Viewers), reset)
+	}
+	fmt.Println()
+
+	idx, saving := *play-1, false
+	if *save > 0 {
+		idx, saving = *save-1, true
+	}
+	if *play == 0 && *save == 0 {
+		var ok bool
+		if idx, saving, ok = pickResult(len(streams)); !ok {
+			return
+		}
+	}
+	if idx < 0 || idx >= len(streams) {
+		fmt.Fprintf(os.Stderr, "no result %d\n", idx+1)
+		os.Exit(1)
+	}
+
+	l := streams[idx]
+	if saving {
+		saveResult(Station{URL: l.URL(), Desc: l.Title}, l.Channel)
+		return
+	}
+	clientPlayURL(l.URL(), l.Title)
+}
+
+// humanCount formats n compactly, e.g. 12345 as "12.3k".
+func humanCount(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%.1fM", float64(n)/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%.1fk", float64(n)/1000)
+	default:
+		return fmt.Sprint(n)
+	}
+}
@@ -0,0 +1,108 @@
+// events.go implements event subscriptions: a client sends "subscribe" and
+// the daemon keeps the connection open, streaming one JSON event per line
+// as playback changes.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// Event types.
+const (
+	evStation   = "station"   // a station started playing
+	evTrack     = "track"     // the track or stream title changed
+	evPause     = "pause"     // playback was paused
+	evResume    = "resume"    // playback was resumed
+	evVolume    = "volume"    // the volume was changed
+	evStop      = "stop"      // playback stopped
+	evError     = "error"     // a station failed to play or its stream ended
+	evReconnect = "reconnect" // a channel station is about to be restarted
+)
+
+// eventBuffer is how many events a subscriber may fall behind before it
+// is dropped.
+const eventBuffer = 32
+
+// Event is a playback change sent to subscribers. Status is the playback
+// state right after the change.
+type Event struct {
+	V       int       `json:"v"`
+	Type    string    `json:"event"`
+	Time    time.Time `json:"time"`
+	Message string    `json:"message,omitempty"`
+	Status  Status    `json:"status"`
+}
+
+// emit sends an event to every subscriber, dropping any that can't keep
+// up. It must be called with d.mu held.
+func (d *Daemon) emit(typ, message string) {
+	slog.Debug("event", "type", typ, "message", message)
+	if len(d.subs) == 0 {
+		return
+	}
+
+	ev := Event{V: protocolVersion, Type: typ, Time: time.Now(), Message: message, Status: d.status()}
+	for ch := range d.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(d.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// unsubscribe removes a subscriber if it is still registered.
+func (d *Daemon) unsubscribe(ch chan Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.subs[ch]; ok {
+		delete(d.subs, ch)
+		close(ch)
+	}
+}
+
+// subscribe registers a new subscriber, returning its event channel and
+// the status as of subscribing. The channel is closed on unsubscribe.
+// Subscribers that are clients, rather than part of the daemon, keep it
+// from exiting when idle.
+func (d *Daemon) subscribe(client bool) (chan Event, Status) {
+	events := make(chan Event, eventBuffer)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.subs == nil {
+		d.subs = make(map[chan Event]bool)
+	}
+	d.subs[events] = client
+	return events, d.status()
+}
+
+// serveEvents answers a subscribe request with the current status, then
+// streams events on conn until the client goes away or falls behind.
+func (d *Daemon) serveEvents(conn net.Conn, req Request, legacy bool) {
+	events, status := d.subscribe(true)
+	defer d.unsubscribe(events)
+
+	if _, err := conn.Write([]byte(encodeResponse(req, legacy, status, "subscribed", nil) + "\n")); err != nil {
+		return
+	}
+
+	// subscribers don't send anything else, so a read ending means they're gone
+	go func() {
+		io.Copy(io.Discard, conn)
+		d.unsubscribe(events)
+	}()
+
+	enc := json.NewEncoder(conn)
+	for ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+	}
+}
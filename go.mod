@@ -4,6 +4,7 @@ go 1.25.5
 
 require (
 	github.com/c-bata/go-prompt v0.2.6
+	github.com/godbus/dbus/v5 v5.2.2
 	golang.org/x/sys v0.39.0
 )
 
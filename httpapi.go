@@ -0,0 +1,212 @@
+// httpapi.go implements the daemon's optional HTTP API, for tools that can't
+// speak the socket protocol. It only listens on loopback, requires the access
+// token, and answers with the same JSON responses as the socket.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// httpStatus maps protocol error codes to HTTP status codes. Codes missing
+// here are answered with 500.
+var httpStatus = map[string]int{
+	errBadRequest:     http.StatusBadRequest,
+	errUnsupported:    http.StatusBadRequest,
+	errUnknownAction:  http.StatusNotFound,
+	errUnknownStation: http.StatusNotFound,
+	errNoStations:     http.StatusConflict,
+	errNoHistory:      http.StatusConflict,
+	errNotPlaying:     http.StatusConflict,
+	errPlayback:       http.StatusBadGateway,
+	errInternal:       http.StatusInternalServerError,
+	errUnauthorized:   http.StatusUnauthorized,
+}
+
+// listenHTTP starts the HTTP API on addr, which must be a loopback address
+// like "localhost:7878".
+func (d *Daemon) listenHTTP(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	if !isLoopback(host) {
+		return fmt.Errorf("http: %s is not a loopback address", host)
+	}
+
+	token, err := loadToken(true)
+	if err != nil {
+		return fmt.Errorf("http: access token: %w", err)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("http: %w", err)
+	}
+	d.httpListener = ln
+
+	go http.Serve(ln, localOnly(requireToken(token, d.httpHandler())))
+	return nil
+}
+
+// isLoopback reports whether host is localhost or a loopback IP.
+func isLoopback(host string) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// httpHandler routes API requests to execute. Any socket action except
+// subscribe can be posted to /<action> with the request fields as the
+// JSON body; /volume takes {"volume": n}.
+func (d *Daemon) httpHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		d.serveHTTP(w, Request{Action: "status"})
+	})
+	mux.HandleFunc("GET /stations", func(w http.ResponseWriter, r *http.Request) {
+		d.serveHTTP(w, Request{Action: "list"})
+	})
+	mux.HandleFunc("POST /volume", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Volume int `json:"volume"`
+		}
+		if err := decodeBody(r, &body); err != nil {
+			writeResponse(w, Request{}, nil, "", err)
+			return
+		}
+		d.serveHTTP(w, Request{Action: "set", Setting: "volume", Value: strconv.Itoa(body.Volume)})
+	})
+	mux.HandleFunc("POST /{action}", func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := decodeBody(r, &req); err != nil {
+			writeResponse(w, Request{}, nil, "", err)
+			return
+		}
+		req.Action = r.PathValue("action")
+		if req.Action == "subscribe" {
+			writeResponse(w, req, nil, "", protoError(errUnknownAction, "subscribe is only available on the socket"))
+			return
+		}
+		d.serveHTTP(w, req)
+	})
+	d.handleDashboard(mux)
+
+	return mux
+}
+
+// serveHTTP executes req and writes the response, shutting the daemon down
+// afterwards if asked to.
+func (d *Daemon) serveHTTP(w http.ResponseWriter, req Request) {
+	result, msg, err := d.execute(req)
+	writeResponse(w, req, result, msg, err)
+
+	if err == nil && (req.Action == "shutdown" || req.Action == "quit") {
+		http.NewResponseController(w).Flush()
+		d.shutdown("shutdown requested over http")
+	}
+}
+
+// decodeBody reads an optional JSON request body into v.
+func decodeBody(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return protoError(errBadRequest, "bad request: "+err.Error())
+	}
+	return nil
+}
+
+// writeResponse writes the protocol response for req as the HTTP body,
+// with a status code matching its error, if any.
+func writeResponse(w http.ResponseWriter, req Request, result any, msg string, err error) {
+	code := http.StatusOK
+	if err != nil {
+		var ok bool
+		if code, ok = httpStatus[asProtoError(err).Code]; !ok {
+			code = http.StatusInternalServerError
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	io.WriteString(w, encodeResponse(req, false, result, msg, err)+"\n")
+}
+
+// localOnly guards the API against web pages the user happens to visit:
+// requests must name a loopback Host, which defeats DNS rebinding, and
+// browser requests, which carry an Origin, are only accepted from browser
+// extensions and local pages.
+func localOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if !isLoopback(strings.Trim(host, "[]")) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if !allowedOrigin(origin) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+			w.Header().Set("Vary", "Origin")
+		}
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireToken admits requests that carry the access token, since every
+// local user can reach the port: as a bearer token, or as a token query
+// parameter for browsers' event streams, which can't set headers. The
+// dashboard's own files are served to anyone; it asks for the token.
+func requireToken(token string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			got = r.URL.Query().Get("token")
+		}
+		if !validToken(got, token) && !(r.Method == http.MethodGet && isDashboardFile(r.URL.Path)) {
+			writeResponse(w, Request{}, nil, "", protoError(errUnauthorized, "missing or invalid token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allowedOrigin reports whether a cross-origin caller may use the API:
+// browser extensions and pages served from loopback.
+func allowedOrigin(origin string) bool {
+	scheme, rest, ok := strings.Cut(origin, "://")
+	if !ok {
+		return false
+	}
+	switch scheme {
+	case "chrome-extension", "moz-extension", "safari-web-extension":
+		return true
+	case "http", "https":
+		host, _, err := net.SplitHostPort(rest)
+		if err != nil {
+			host = rest
+		}
+		return isLoopback(strings.Trim(host, "[]"))
+	}
+	return false
+}
@@ -0,0 +1,59 @@
+// idle.go implements the daemon's idle timeout: a daemon with nothing to
+// do for long enough exits on its own.
+
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultIdleTimeout is how long a daemon may sit idle before exiting.
+const defaultIdleTimeout = 30 * time.Minute
+
+// idle reports whether the daemon has nothing to do: nothing playing or
+// paused, no queue or reconnect pending, no client following events, and
+// no HTTP or remote listener, whose clients couldn't start it again.
+// Called with d.mu held.
+func (d *Daemon) idle() bool {
+	if d.httpListener != nil || d.remoteListener != nil {
+		return false
+	}
+	if d.cmd != nil || d.station != nil || d.paused || d.reconnect != nil {
+		return false
+	}
+	if d.segment != nil || len(d.queue) > 0 {
+		return false
+	}
+	for _, client := range d.subs {
+		if client {
+			return false
+		}
+	}
+	return true
+}
+
+// touch restarts the idle clock. Called with d.mu held.
+func (d *Daemon) touch() {
+	d.activeAt = time.Now()
+}
+
+// exitWhenIdle shuts the daemon down once it has been idle for timeout
+// since it was last used.
+func (d *Daemon) exitWhenIdle(timeout time.Duration) {
+	tick := time.NewTicker(max(min(timeout/4, time.Minute), time.Second))
+	defer tick.Stop()
+
+	d.mu.Lock()
+	d.touch()
+	d.mu.Unlock()
+	for range tick.C {
+		d.mu.Lock()
+		if !d.idle() {
+			d.touch()
+		} else if time.Since(d.activeAt) >= timeout {
+			d.exit(fmt.Sprintf("idle for %v", timeout))
+		}
+		d.mu.Unlock()
+	}
+}
@@ -0,0 +1,108 @@
+// instance.go implements named daemon profiles: independent daemons that
+// run side by side, each with its own socket, state and audio output,
+// selected with --profile.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultProfile is how the daemon run without --profile is listed.
+const defaultProfile = "default"
+
+// daemonProfile is the daemon profile in use, set by --profile or
+// CHILL_PROFILE. Empty means the default daemon.
+var daemonProfile string
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,31}$`)
+
+// setProfile selects the daemon profile, checking that name is usable in
+// file names. "default" selects the default daemon.
+func setProfile(name string) error {
+	if name == defaultProfile {
+		name = ""
+	}
+	if name != "" && !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile %q: use up to 32 letters, digits, - and _", name)
+	}
+	daemonProfile = name
+	return nil
+}
+
+// profileLabel is the current profile's name for display.
+func profileLabel() string {
+	if daemonProfile == "" {
+		return defaultProfile
+	}
+	return daemonProfile
+}
+
+// socketName returns the file name of a profile's socket (or, on Windows,
+// port file) with the given extension: chill.sock, chill.speakers.sock.
+func socketName(profile, ext string) string {
+	if profile == "" {
+		return "chill." + ext
+	}
+	return "chill." + profile + "." + ext
+}
+
+// profileFromSocket is the inverse of socketName.
+func profileFromSocket(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(strings.TrimPrefix(base, "chill."), filepath.Ext(base))
+}
+
+// listProfiles prints each running daemon profile and what it's playing.
+func listProfiles() {
+	if remoteAddr != "" {
+		fail(errors.New("--profiles only lists daemons on this machine"))
+		return
+	}
+
+	paths, _ := filepath.Glob(socketFile("*"))
+	names := []string{""}
+	for _, p := range paths {
+		if name := profileFromSocket(p); profileName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	selected := daemonProfile
+	defer func() { daemonProfile = selected }()
+
+	running := 0
+	for _, name := range names {
+		// query each daemon as if it had been picked with --profile
+		daemonProfile = name
+		if !isDaemonRunning() {
+			continue
+		}
+		running++
+
+		state := dim + "idle" + reset
+		resp, err := call(Request{Action: "status"})
+		var s Status
+		switch {
+		case err != nil:
+			state = dim + err.Error() + reset
+		case json.Unmarshal(resp.Result, &s) == nil && s.Playing:
+			state = purple + "▶ " + reset + pink + s.Desc + reset
+		case s.Paused:
+			state = dim + "⏸ " + s.Desc + reset
+		case s.Reconnecting != "":
+			state = dim + "reconnecting to " + s.Reconnecting + reset
+		}
+		fmt.Printf("%s%-16s%s  %s\n", cyan, profileLabel(), reset, state)
+	}
+	if running == 0 {
+		fmt.Println(dim + "no daemons running" + reset)
+	}
+}
@@ -0,0 +1,95 @@
+// local.go supports stations backed by a local directory or glob of audio
+// files, played by mpv as a shuffled, endlessly looping playlist.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// audioExts lists the file extensions treated as audio tracks.
+var audioExts = map[string]bool{
+	".mp3": true, ".flac": true, ".ogg": true, ".opus": true, ".m4a": true,
+	".aac": true, ".wav": true, ".wma": true, ".aiff": true, ".ape": true,
+}
+
+// expandHome replaces a leading "~" with the user's home directory.
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") || strings.HasPrefix(path, `~\`) {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
+
+// localTracks returns the audio files for path, which is either a directory
+// (searched recursively) or a glob pattern.
+func localTracks(path string) ([]string, error) {
+	path = expandHome(path)
+
+	var tracks []string
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		err := filepath.WalkDir(path, func(p string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // skip unreadable entries
+			}
+			if !e.IsDir() && audioExts[strings.ToLower(filepath.Ext(p))] {
+				tracks = append(tracks, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		matches, err := filepath.Glob(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			if audioExts[strings.ToLower(filepath.Ext(m))] {
+				tracks = append(tracks, m)
+			}
+		}
+	}
+
+	if len(tracks) == 0 {
+		return nil, errors.New("no audio files in " + path)
+	}
+	sort.Strings(tracks)
+	return tracks, nil
+}
+
+// playlistPath returns where the daemon writes local station playlists:
+// the private runtime directory, like mpv's socket, since the playlist
+// lists the user's files.
+func playlistPath() string {
+	return filepath.Join(runtimeDir(), fmt.Sprintf("chill-%d.m3u", os.Getpid()))
+}
+
+// localPlaylistArgs writes the station's tracks to a playlist file and
+// returns the mpv arguments that play it shuffled and looping forever.
+func localPlaylistArgs(path string) ([]string, error) {
+	tracks, err := localTracks(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := secureRuntimeDir(); err != nil {
+		return nil, err
+	}
+	playlist := playlistPath()
+	data := "#EXTM3U\n" + strings.Join(tracks, "\n") + "\n"
+	if err := os.WriteFile(playlist, []byte(data), 0600); err != nil {
+		return nil, err
+	}
+
+	return []string{"--shuffle", "--loop-playlist=inf", "--playlist=" + playlist}, nil
+}
@@ -0,0 +1,209 @@
+// log.go implements the daemon's log: leveled, structured lines in the
+// state directory, rotated by size, and chill --logs to read them.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxLogSize = 1 << 20 // rotate the log past this many bytes
+	logBackups = 3       // rotated logs kept: chill.log.1 to chill.log.3
+	logTail    = 50      // lines chill --logs shows before following
+)
+
+// logPath returns the daemon's log file. Each daemon profile has its own.
+func logPath() string {
+	return filepath.Join(stateDir(), "chill.log")
+}
+
+// rotatingFile is an append-only log file that rotates itself once it
+// grows past maxLogSize.
+type rotatingFile struct {
+	mu     sync.Mutex
+	path   string
+	f      *os.File // nil if reopening after a rotation failed
+	size   int64
+	stderr bool // the process's stderr is the log too, and follows it
+}
+
+// openRotating opens the log at path. If stderr already goes to it, as
+// for a spawned daemon, stderr is moved along with each rotation.
+func openRotating(path string) (*rotatingFile, error) {
+	r := &rotatingFile{path: path}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if fi, err := os.Stderr.Stat(); err == nil {
+		if lfi, err := os.Stat(path); err == nil && os.SameFile(fi, lfi) {
+			r.stderr = true
+		}
+	}
+	return r, r.open()
+}
+
+func (r *rotatingFile) open() error {
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.f, r.size = f, fi.Size()
+	if r.stderr {
+		redirectStderr(f)
+	}
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.f == nil {
+		if err := r.open(); err != nil {
+			return 0, err
+		}
+	}
+	if r.stderr {
+		// writes to stderr grow the file too
+		if fi, err := r.f.Stat(); err == nil {
+			r.size = fi.Size()
+		}
+	}
+	if r.size > 0 && r.size+int64(len(p)) > maxLogSize {
+		r.rotate()
+		if r.f == nil {
+			return 0, errors.New("log: reopening after rotation failed")
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate shifts chill.log to chill.log.1, .1 to .2 and so on, dropping the
+// oldest, and starts a new file. Every handle on the log, stderr's too, is
+// closed first, since Windows can't rename an open file. If renaming fails
+// it keeps appending to the same file.
+func (r *rotatingFile) rotate() {
+	r.f.Close()
+	r.f = nil
+	if r.stderr {
+		if null, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			redirectStderr(null)
+			null.Close()
+		}
+	}
+
+	for i := logBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	os.Rename(r.path, r.path+".1")
+	r.open()
+}
+
+// initLog sends the daemon's log output to its log file, at the given
+// level (debug, info, warn or error; info if empty).
+func initLog(level string) error {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return fmt.Errorf("log_level: %w", err)
+		}
+	}
+	f, err := openRotating(logPath())
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(f, &slog.HandlerOptions{Level: lvl})))
+	return nil
+}
+
+// lineLogger is an io.Writer that logs each line written to it, for
+// capturing a subprocess's output.
+type lineLogger struct {
+	level slog.Level
+	msg   string
+	attrs []any
+	buf   []byte
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(l.buf[:i])); line != "" {
+			slog.Log(context.Background(), l.level, l.msg, append(l.attrs, "output", line)...)
+		}
+		l.buf = l.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// showLogs prints the end of the daemon's log, then with follow keeps
+// printing lines as they are written, across rotations, until interrupted.
+func showLogs(follow bool) {
+	if remoteAddr != "" {
+		fail(errors.New("--logs only shows daemons on this machine"))
+		return
+	}
+
+	path := logPath()
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Println(dim + "no logs yet (" + path + ")" + reset)
+			return
+		}
+		fail(err)
+		return
+	}
+	defer func() { f.Close() }()
+
+	data, _ := io.ReadAll(f)
+	lines := strings.SplitAfter(string(data), "\n")
+	if n := len(lines); n > logTail+1 {
+		lines = lines[n-logTail-1:]
+	}
+	fmt.Print(strings.Join(lines, ""))
+	if !follow {
+		return
+	}
+
+	for {
+		time.Sleep(250 * time.Millisecond)
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			fail(err)
+			return
+		}
+
+		// the daemon rotated the log: finish with the old file, then switch
+		cur, err1 := f.Stat()
+		next, err2 := os.Stat(path)
+		if err1 == nil && err2 == nil && !os.SameFile(cur, next) {
+			io.Copy(os.Stdout, f)
+			if nf, err := os.Open(path); err == nil {
+				f.Close()
+				f = nf
+			}
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// redirectStderr points the process's stderr, where the Go runtime writes
+// panics and fatal errors, at f.
+func redirectStderr(f *os.File) error {
+	return unix.Dup2(int(f.Fd()), int(os.Stderr.Fd()))
+}
@@ -0,0 +1,28 @@
+//go:build windows
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// redirectStderr points the process's standard error handle, where the Go
+// runtime writes panics and fatal errors, at a copy of f's handle, and
+// closes the old one so it doesn't keep a rotated log open.
+func redirectStderr(f *os.File) error {
+	proc := windows.CurrentProcess()
+	var h windows.Handle
+	if err := windows.DuplicateHandle(proc, windows.Handle(f.Fd()), proc, &h, 0, false, windows.DUPLICATE_SAME_ACCESS); err != nil {
+		return err
+	}
+	if err := windows.SetStdHandle(windows.STD_ERROR_HANDLE, h); err != nil {
+		windows.CloseHandle(h)
+		return err
+	}
+	old := os.Stderr
+	os.Stderr = os.NewFile(uintptr(h), "stderr")
+	old.Close()
+	return nil
+}
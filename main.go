@@ -13,12 +13,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"math/rand"
 	"os"
 	"os/exec"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -59,20 +61,38 @@ var vibes = []string{
 }
 
 // Station represents a lofi radio stream with a name, YouTube URL, and description.
+// A station with a Channel is resolved to that channel's current live stream
+// at play time; URL is then only a fallback. A station with a Path plays local
+// audio files instead.
 type Station struct {
-	Name string // short identifier (e.g., "lofi-girl")
-	URL  string // YouTube video/stream URL
-	Desc string // human-readable description
+	Name    string   `json:"name"`              // short identifier (e.g., "lofi-girl")
+	URL     string   `json:"url,omitempty"`     // YouTube video/stream URL
+	Desc    string   `json:"desc"`              // human-readable description
+	Channel string   `json:"channel,omitempty"` // YouTube channel handle (e.g., "@LofiGirl")
+	Match   string   `json:"match,omitempty"`   // picks among a channel's live streams by title
+	Tags    []string `json:"tags,omitempty"`    // moods/genres (e.g., "focus", "sleep", "jazz")
+	Path    string   `json:"path,omitempty"`    // local directory or glob of audio files
+	Profile Profile  `json:"profile,omitzero"`  // volume, EQ and mpv settings
+}
+
+// HasTag reports whether the station is tagged with tag (case-insensitive).
+func (s Station) HasTag(tag string) bool {
+	for _, t := range s.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
 // stations contains the available 24/7 lofi radio streams.
 var stations = []Station{
-	{"lofi-girl", "https://www.youtube.com/watch?v=jfKfPfyJRdk", "Lofi Girl - beats to relax/study to"},
-	{"chillhop", "https://www.youtube.com/watch?v=5yx6BWlEVcY", "Chillhop Radio - jazzy & lofi hip hop"},
-	{"chillout", "https://www.youtube.com/watch?v=9UMxZofMNbA", "Chillout Lounge - calm & relaxing"},
-	{"code-radio", "https://www.youtube.com/watch?v=ByZGu229-yA", "Code Radio - beats to study & code to"},
-	{"sleep", "https://www.youtube.com/watch?v=rPjez8z61rI", "Lofi - beats to sleep/relax to"},
-	{"study", "https://www.youtube.com/watch?v=7NOSDKb0HlU", "Lofi - beats to study/relax to"},
+	{Name: "lofi-girl", URL: "https://www.youtube.com/watch?v=jfKfPfyJRdk", Desc: "Lofi Girl - beats to relax/study to", Channel: "@LofiGirl", Match: "relax/study", Tags: []string{"focus", "study"}},
+	{Name: "chillhop", URL: "https://www.youtube.com/watch?v=5yx6BWlEVcY", Desc: "Chillhop Radio - jazzy & lofi hip hop", Channel: "@ChillhopMusic", Match: "chillhop radio", Tags: []string{"jazz", "hiphop"}},
+	{Name: "chillout", URL: "https://www.youtube.com/watch?v=9UMxZofMNbA", Desc: "Chillout Lounge - calm & relaxing", Tags: []string{"relax", "ambient"}},
+	{Name: "code-radio", URL: "https://www.youtube.com/watch?v=ByZGu229-yA", Desc: "Code Radio - beats to study & code to", Tags: []string{"focus", "code"}},
+	{Name: "sleep", URL: "https://www.youtube.com/watch?v=rPjez8z61rI", Desc: "Lofi - beats to sleep/relax to", Tags: []string{"sleep", "relax"}},
+	{Name: "study", URL: "https://www.youtube.com/watch?v=7NOSDKb0HlU", Desc: "Lofi - beats to study/relax to", Tags: []string{"focus", "study"}},
 }
 
 func init() {
@@ -91,13 +111,40 @@ func main() {
 	status := flag.Bool("status", false, "show current status")
 	toggle := flag.Bool("toggle", false, "toggle play/pause")
 	skip := flag.Bool("skip", false, "skip to random station")
+	next := flag.Bool("next", false, "play the next station in the catalog")
+	prev := flag.Bool("prev", false, "go back to the previous station")
+	queue := flag.String("queue", "", `play a queue, e.g. "chillhop 30m, code-radio 1h, sleep"`)
+	resume := flag.Bool("resume", false, "resume the last station played, even after a restart")
 	stop := flag.Bool("stop", false, "stop playback")
+	shutdown := flag.Bool("shutdown", false, "stop playback and shut down the daemon")
+	rate := flag.String("rate", "", "rate the current station up, down or clear")
+	check := flag.Bool("check", false, "check which stations are live")
+	watch := flag.Bool("watch", false, "print playback events as they happen")
+	profiles := flag.Bool("profiles", false, "list running daemon profiles")
+	logs := flag.Bool("logs", false, "show the daemon's log")
 	fg := flag.Bool("fg", false, "run in foreground (no daemon)")
 
 	// options
 	station := flag.String("station", "", "station to play")
+	tag := flag.String("tag", "", "only pick stations with this tag (with --skip)")
+	follow := flag.Bool("f", false, "keep printing new log lines (with --logs)")
+	jsonOut := flag.Bool("json", false, "print machine-readable output (with --check or --watch)")
+	volume := flag.Int("volume", 0, "set volume for this session (1-130)")
+	profile := flag.String("profile", os.Getenv("CHILL_PROFILE"), "use a named daemon profile, with its own socket, state and audio device")
+	remote := flag.String("remote", os.Getenv("CHILL_REMOTE"), "control the daemon at host:port instead of the local one")
+	eq := flag.String("eq", "", "set EQ preset for this session ("+strings.Join(eqNames(), ", ")+")")
+	mute := flag.String("mute", "", "mute or unmute for this session (on, off)")
 
 	flag.Parse()
+	remoteAddr = *remote
+	if err := setProfile(*profile); err != nil {
+		fmt.Fprintln(os.Stderr, "error: "+err.Error())
+		os.Exit(1)
+	}
+
+	if err := loadCatalog(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
 
 	switch {
 	case *daemon:
@@ -111,9 +158,51 @@ func main() {
 	case *toggle:
 		clientToggle()
 	case *skip:
-		clientSkip()
+		clientSkip(*tag)
+	case *next:
+		clientNavigate("next")
+	case *prev:
+		clientNavigate("prev")
+	case *resume:
+		clientResume()
 	case *stop:
 		clientStop()
+	case *shutdown:
+		clientShutdown()
+	case *rate != "":
+		clientRate(*rate, *station)
+	case *check:
+		runCheck(*jsonOut)
+	case *watch:
+		clientWatch(*jsonOut)
+	case *profiles:
+		listProfiles()
+	case *logs:
+		showLogs(*follow)
+	case *volume != 0 || *eq != "" || *mute != "":
+		if *volume != 0 {
+			clientSet("volume", strconv.Itoa(*volume))
+		}
+		if *eq != "" {
+			clientSet("eq", *eq)
+		}
+		if *mute != "" {
+			clientSet("mute", *mute)
+		}
+		if s := *station; s != "" || flag.NArg() > 0 {
+			if s == "" {
+				s = flag.Arg(0)
+			}
+			clientPlay(s)
+		}
+	case flag.Arg(0) == "search":
+		runSearch(flag.Args()[1:])
+	case flag.Arg(0) == "discover":
+		runDiscover(flag.Args()[1:])
+	case flag.Arg(0) == "queue":
+		clientQueue(flag.Args()[1:])
+	case *queue != "":
+		clientQueue([]string{"set", *queue})
 	case *fg:
 		// foreground mode (original behavior)
 		s := *station
@@ -123,9 +212,9 @@ func main() {
 		if s == "" {
 			s = "lofi-girl"
 		}
-		st := findStation(s)
+		st, suggestions := matchStation(s)
 		if st == nil {
-			fmt.Fprintf(os.Stderr, "unknown station: %s\n", s)
+			fmt.Fprintln(os.Stderr, unknownStation(s, suggestions))
 			os.Exit(1)
 		}
 		playForeground(st)
@@ -135,10 +224,7 @@ func main() {
 		if s == "" && flag.NArg() > 0 {
 			s = flag.Arg(0)
 		}
-		if s == "" {
-			s = "lofi-girl"
-		}
-		clientPlay(s)
+		clientPlay(s) // the daemon picks the default station, or resumes
 	}
 }
 
@@ -148,7 +234,7 @@ func printStations() {
 	fmt.Println(dim + "  available stations:" + reset)
 	fmt.Println()
 	for _, s := range stations {
-		fmt.Printf("    %s%-16s%s  %s%s%s\n", cyan, s.Name, reset, dim, s.Desc, reset)
+		fmt.Printf("    %s%-16s%s  %s%s%s%s\n", cyan, s.Name, reset, dim, s.Desc, formatTags(s.Tags), reset)
 	}
 	fmt.Println()
 	fmt.Println(dim + "  usage:" + reset)
@@ -157,13 +243,81 @@ func printStations() {
 	fmt.Printf("    %schill chillhop%s     %splay specific station%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %schill -i%s           %sinteractive mode (repl)%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %schill --skip%s       %sskip to random station%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --next%s       %snext station (--prev goes back)%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill tag:sleep%s    %splay a station tagged sleep%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --queue%s      %squeue stations, e.g. \"chillhop 30m, sleep\"%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --rate up%s    %sskip picks this station more often%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --volume 50%s  %sset volume for this session%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --resume%s     %sresume the last station, even after a restart%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %schill --toggle%s     %spause/resume%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %schill --status%s     %sshow what's playing%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --watch%s      %sfollow playback events live%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %schill --stop%s       %sstop playback%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --shutdown%s   %sstop playback and the daemon%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --logs%s       %sshow the daemon's log (-f to follow)%s\n", cyan, reset, dim, reset)
 	fmt.Printf("    %schill --fg%s         %srun in foreground%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --profile%s    %suse a separate daemon, e.g. for speakers%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --remote%s     %scontrol a daemon on another machine%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill --check%s      %scheck which stations are live%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill search jazz%s  %ssearch internet radio%s\n", cyan, reset, dim, reset)
+	fmt.Printf("    %schill discover%s     %sfind live youtube streams%s\n", cyan, reset, dim, reset)
 	fmt.Println()
 }
 
+// formatTags renders tags for station listings, e.g. "  #focus #study".
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	return "  #" + strings.Join(tags, " #")
+}
+
+// allTags returns every tag used in the catalog, in first-seen order.
+func allTags() []string {
+	var tags []string
+	seen := map[string]bool{}
+	for _, s := range stations {
+		for _, t := range s.Tags {
+			if !seen[strings.ToLower(t)] {
+				seen[strings.ToLower(t)] = true
+				tags = append(tags, t)
+			}
+		}
+	}
+	return tags
+}
+
+// stationsWithTag returns the stations tagged with tag, or all stations if
+// tag is empty.
+func stationsWithTag(tag string) []Station {
+	if tag == "" {
+		return stations
+	}
+	var matched []Station
+	for _, s := range stations {
+		if s.HasTag(tag) {
+			matched = append(matched, s)
+		}
+	}
+	return matched
+}
+
+// parseInterspersed parses args with fs, allowing flags to appear after
+// positional arguments (e.g. "chill search jazz --tag lofi"). It returns
+// the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
 // findStation returns the station with the given name (case-insensitive),
 // or nil if no matching station is found.
 func findStation(name string) *Station {
@@ -181,6 +335,27 @@ func findStation(name string) *Station {
 func playForeground(s *Station) {
 	vibe := vibes[randInt(len(vibes))]
 
+	source := []string{s.URL}
+	switch {
+	case s.Path != "":
+		args, err := localPlaylistArgs(s.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+			os.Exit(1)
+		}
+		source = args
+	case s.Channel != "":
+		ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+		live, err := resolveLive(ctx, s.Channel, s.Match)
+		cancel()
+		if err == nil {
+			source = []string{live}
+		} else if s.URL == "" {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", s.Channel, err)
+			os.Exit(1)
+		}
+	}
+
 	fmt.Print("\033[2J\033[H")
 	fmt.Print(logo)
 	fmt.Printf("  %s♪ %s%s\n", pink, s.Desc, reset)
@@ -194,8 +369,9 @@ func playForeground(s *Station) {
 		"--term-status-msg=  ${playback-time} │ ${audio-codec-name} ${audio-params/samplerate}Hz │ ${audio-bitrate}",
 		"--msg-level=all=no,statusline=status",
 		"--volume=70",
-		s.URL,
 	)
+	cmd.Args = append(cmd.Args, s.Profile.MPVArgs()...)
+	cmd.Args = append(cmd.Args, source...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	cmd.Stdin = os.Stdin
@@ -211,7 +387,11 @@ func playForeground(s *Station) {
 		}
 	}()
 
-	if err := cmd.Run(); err != nil {
+	err := cmd.Run()
+	if s.Path != "" {
+		os.Remove(playlistPath())
+	}
+	if err != nil {
 		if exitErr, ok := err.(*exec.ExitError); ok {
 			if exitErr.ExitCode() == -1 {
 				return
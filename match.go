@@ -0,0 +1,144 @@
+// match.go resolves loosely typed station names. Exact names win, then
+// unique name prefixes, then fuzzy matches on the words of a station's name,
+// description and tags. When the input is ambiguous, ranked suggestions are
+// returned instead.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxSuggestions is how many stations "did you mean" offers.
+const maxSuggestions = 3
+
+// matchStation resolves query to a station. If it cannot be resolved
+// unambiguously, it returns nil and the closest stations, best first.
+func matchStation(query string) (*Station, []Station) {
+	if s := findStation(query); s != nil {
+		return s, nil
+	}
+
+	q := strings.ToLower(strings.TrimSpace(query))
+	if q == "" {
+		return nil, nil
+	}
+
+	// unique prefix of a station name
+	var prefixed []Station
+	for _, s := range stations {
+		if strings.HasPrefix(strings.ToLower(s.Name), q) {
+			prefixed = append(prefixed, s)
+		}
+	}
+	if len(prefixed) == 1 {
+		return &prefixed[0], nil
+	}
+	if len(prefixed) > 1 {
+		sort.SliceStable(prefixed, func(i, j int) bool {
+			return len(prefixed[i].Name) < len(prefixed[j].Name)
+		})
+		return nil, limitStations(prefixed, maxSuggestions)
+	}
+
+	// fuzzy match on words
+	type scored struct {
+		station Station
+		dist    int
+	}
+	threshold := 1
+	if len(q) > 4 {
+		threshold = 2
+	}
+
+	var matches []scored
+	for _, s := range stations {
+		if d := matchDistance(q, s); d <= threshold {
+			matches = append(matches, scored{s, d})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].dist < matches[j].dist
+	})
+
+	if len(matches) == 1 || (len(matches) > 1 && matches[0].dist < matches[1].dist) {
+		return &matches[0].station, nil
+	}
+
+	var suggestions []Station
+	for _, m := range matches {
+		suggestions = append(suggestions, m.station)
+	}
+	return nil, limitStations(suggestions, maxSuggestions)
+}
+
+// matchDistance returns how far query is from station: 0 if it prefixes a
+// word of the station's name, description or tags, otherwise the smallest
+// edit distance to the whole name or any of those words.
+func matchDistance(query string, s Station) int {
+	best := editDistance(query, strings.ToLower(s.Name))
+
+	text := s.Name + " " + s.Desc + " " + strings.Join(s.Tags, " ")
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if strings.HasPrefix(w, query) {
+			return 0
+		}
+		best = min(best, editDistance(query, w))
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+// limitStations returns at most n stations from s.
+func limitStations(s []Station, n int) []Station {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// unknownStation formats the error for an unresolved name, with any
+// suggestions, e.g. "unknown station: chil (did you mean chillhop or chillout?)".
+func unknownStation(name string, suggestions []Station) string {
+	if len(suggestions) == 0 {
+		return "unknown station: " + name
+	}
+
+	names := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		names[i] = s.Name
+	}
+	list := names[0]
+	if len(names) > 1 {
+		list = strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+	}
+	return fmt.Sprintf("unknown station: %s (did you mean %s?)", name, list)
+}
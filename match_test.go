@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// testCatalog replaces the station catalog for the rest of the test.
+func testCatalog(t *testing.T) {
+	saved := stations
+	t.Cleanup(func() { stations = saved })
+	stations = []Station{
+		{Name: "lofi-girl", Desc: "Lofi Girl - beats to relax/study to", Tags: []string{"focus", "study"}},
+		{Name: "chillhop", Desc: "Chillhop Radio - jazzy & lofi hip hop", Tags: []string{"jazz"}},
+		{Name: "chillout", Desc: "Chillout Lounge - calm & relaxing", Tags: []string{"calm"}},
+		{Name: "code-radio", Desc: "Code Radio - music for coding", Tags: []string{"focus"}},
+		{Name: "synthwave", Desc: "Synthwave Radio - retro beats", Tags: []string{"retro"}},
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"chillhop", "chillhop", 0},
+		{"chilhop", "chillhop", 1},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"café", "cafe", 1},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d; want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d; want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMatchStation(t *testing.T) {
+	testCatalog(t)
+	tests := []struct {
+		name        string
+		query       string
+		want        string   // matched station, if any
+		suggestions []string // offered instead
+	}{
+		{"exact", "chillhop", "chillhop", nil},
+		{"exact ignores case", "ChillHop", "chillhop", nil},
+		{"unique prefix", "code", "code-radio", nil},
+		{"ambiguous prefix", "chill", "", []string{"chillhop", "chillout"}},
+		{"typo", "chilhop", "chillhop", nil},
+		{"typo near the start", "sinthwave", "synthwave", nil},
+		{"word of the description", "jazz", "chillhop", nil},
+		{"tag", "retro", "synthwave", nil},
+		{"ambiguous word", "radio", "", []string{"chillhop", "code-radio", "synthwave"}},
+		{"ambiguous tag", "focus", "", []string{"lofi-girl", "code-radio"}},
+		{"no match", "polka", "", nil},
+		{"empty", "  ", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			station, suggestions := matchStation(tt.query)
+			var got string
+			if station != nil {
+				got = station.Name
+			}
+			var names []string
+			for _, s := range suggestions {
+				names = append(names, s.Name)
+			}
+			if got != tt.want || !reflect.DeepEqual(names, tt.suggestions) {
+				t.Errorf("matchStation(%q) = %q, %q; want %q, %q", tt.query, got, names, tt.want, tt.suggestions)
+			}
+		})
+	}
+}
+
+func TestUnknownStation(t *testing.T) {
+	tests := []struct {
+		suggestions []string
+		want        string
+	}{
+		{nil, "unknown station: chil"},
+		{[]string{"chillhop"}, "unknown station: chil (did you mean chillhop?)"},
+		{[]string{"chillhop", "chillout"}, "unknown station: chil (did you mean chillhop or chillout?)"},
+		{[]string{"chillhop", "chillout", "chill-jazz"}, "unknown station: chil (did you mean chillhop, chillout or chill-jazz?)"},
+	}
+	for _, tt := range tests {
+		var suggestions []Station
+		for _, name := range tt.suggestions {
+			suggestions = append(suggestions, Station{Name: name})
+		}
+		if got := unknownStation("chil", suggestions); got != tt.want {
+			t.Errorf("unknownStation(%q) = %q; want %q", tt.suggestions, got, tt.want)
+		}
+	}
+}
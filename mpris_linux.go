@@ -0,0 +1,273 @@
+// mpris_linux.go exposes the daemon on the D-Bus session bus as an MPRIS
+// media player, so media keys and desktop widgets can control chill.
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"strconv"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+const (
+	mprisName    = "org.mpris.MediaPlayer2.chill"
+	mprisPath    = "/org/mpris/MediaPlayer2"
+	mprisRoot    = "org.mpris.MediaPlayer2"
+	mprisPlayer  = "org.mpris.MediaPlayer2.Player"
+	mprisNoTrack = "/org/mpris/MediaPlayer2/TrackList/NoTrack"
+)
+
+// mprisApp implements the org.mpris.MediaPlayer2 interface.
+type mprisApp struct{ d *Daemon }
+
+func (m mprisApp) Raise() *dbus.Error { return nil }
+
+func (m mprisApp) Quit() *dbus.Error {
+	go m.d.shutdown("quit over mpris") // after the reply goes out
+	return nil
+}
+
+// mprisControls implements org.mpris.MediaPlayer2.Player by running daemon
+// actions.
+type mprisControls struct{ d *Daemon }
+
+// run executes a daemon request, converting failures to D-Bus errors.
+func (m mprisControls) run(req Request) (Status, *dbus.Error) {
+	result, _, err := m.d.execute(req)
+	if err != nil {
+		return Status{}, dbus.MakeFailedError(err)
+	}
+	s, _ := result.(Status)
+	return s, nil
+}
+
+// current returns the playback status.
+func (m mprisControls) current() Status {
+	s, _ := m.run(Request{Action: "status"})
+	return s
+}
+
+func (m mprisControls) Next() *dbus.Error {
+	_, err := m.run(Request{Action: "next"})
+	return err
+}
+
+func (m mprisControls) Previous() *dbus.Error {
+	_, err := m.run(Request{Action: "prev"})
+	return err
+}
+
+func (m mprisControls) Pause() *dbus.Error {
+	if !m.current().Playing {
+		return nil
+	}
+	_, err := m.run(Request{Action: "pause"})
+	return err
+}
+
+func (m mprisControls) Play() *dbus.Error {
+	s := m.current()
+	switch {
+	case s.Playing:
+		return nil
+	case s.Paused:
+		_, err := m.run(Request{Action: "resume"})
+		return err
+	}
+	_, err := m.run(Request{Action: "play"})
+	return err
+}
+
+func (m mprisControls) PlayPause() *dbus.Error {
+	if m.current().Playing {
+		return m.Pause()
+	}
+	return m.Play()
+}
+
+func (m mprisControls) Stop() *dbus.Error {
+	_, err := m.run(Request{Action: "stop"})
+	return err
+}
+
+func (m mprisControls) OpenUri(uri string) *dbus.Error {
+	_, err := m.run(Request{Action: "play", Station: uri})
+	return err
+}
+
+// SeekBy (exported as Seek, a name vet reserves for io.Seeker) and
+// SetPosition do nothing: live streams can't seek.
+func (m mprisControls) SeekBy(offset int64) *dbus.Error { return nil }
+
+func (m mprisControls) SetPosition(track dbus.ObjectPath, pos int64) *dbus.Error { return nil }
+
+// startMPRIS registers the daemon on the session bus. It does nothing if
+// there is no session bus; one is never launched just for chill. If another
+// daemon already owns the name, a per-process instance name is used, as the
+// MPRIS spec suggests.
+func (d *Daemon) startMPRIS() {
+	conn, err := dbus.SessionBusPrivateNoAutoStartup()
+	if err != nil {
+		return
+	}
+	if err := conn.Auth(nil); err != nil {
+		conn.Close()
+		return
+	}
+	if err := conn.Hello(); err != nil {
+		conn.Close()
+		return
+	}
+
+	reply, err := conn.RequestName(mprisName, dbus.NameFlagDoNotQueue)
+	if err == nil && reply != dbus.RequestNameReplyPrimaryOwner {
+		reply, err = conn.RequestName(fmt.Sprintf("%s.instance%d", mprisName, os.Getpid()), dbus.NameFlagDoNotQueue)
+	}
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		conn.Close()
+		return
+	}
+
+	identity := "chill"
+	if daemonProfile != "" {
+		identity += " (" + daemonProfile + ")"
+	}
+
+	controls := mprisControls{d}
+	renames := map[string]string{"SeekBy": "Seek"}
+	conn.Export(mprisApp{d}, mprisPath, mprisRoot)
+	conn.ExportWithMap(controls, renames, mprisPath, mprisPlayer)
+
+	readOnly := func(v any) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+	props, err := prop.Export(conn, mprisPath, prop.Map{
+		mprisRoot: {
+			"CanQuit":             readOnly(true),
+			"CanRaise":            readOnly(false),
+			"HasTrackList":        readOnly(false),
+			"Identity":            readOnly(identity),
+			"SupportedUriSchemes": readOnly([]string{"http", "https"}),
+			"SupportedMimeTypes":  readOnly([]string{}),
+		},
+		mprisPlayer: {
+			"PlaybackStatus": readOnly("Stopped"),
+			"Rate":           readOnly(1.0),
+			"MinimumRate":    readOnly(1.0),
+			"MaximumRate":    readOnly(1.0),
+			"Metadata":       readOnly(mprisMetadata(Status{}, 0)),
+			"Volume": {
+				Value:    1.0,
+				Writable: true,
+				Emit:     prop.EmitTrue,
+				Callback: func(c *prop.Change) *dbus.Error {
+					f, ok := c.Value.(float64)
+					if !ok || math.IsNaN(f) {
+						return prop.ErrInvalidArg
+					}
+					v := int(math.Round(max(0, min(f, 2)) * 100))
+					v = max(1, min(v, maxVolume))
+					_, err := controls.run(Request{Action: "set", Setting: "volume", Value: strconv.Itoa(v)})
+					return err
+				},
+			},
+			"Position":      readOnly(int64(0)),
+			"CanGoNext":     readOnly(true),
+			"CanGoPrevious": readOnly(true),
+			"CanPlay":       readOnly(true),
+			"CanPause":      readOnly(true),
+			"CanSeek":       readOnly(false),
+			"CanControl":    readOnly(true),
+		},
+	})
+	if err != nil {
+		conn.Close()
+		return
+	}
+
+	methods := introspect.Methods(controls)
+	for i, m := range methods {
+		if name, ok := renames[m.Name]; ok {
+			methods[i].Name = name
+		}
+	}
+	node := &introspect.Node{
+		Name: mprisPath,
+		Interfaces: []introspect.Interface{
+			introspect.IntrospectData,
+			prop.IntrospectData,
+			{Name: mprisRoot, Methods: introspect.Methods(mprisApp{}), Properties: props.Introspection(mprisRoot)},
+			{Name: mprisPlayer, Methods: methods, Properties: props.Introspection(mprisPlayer)},
+		},
+	}
+	conn.Export(introspect.NewIntrospectable(node), mprisPath, "org.freedesktop.DBus.Introspectable")
+
+	go d.followMPRIS(props)
+}
+
+// followMPRIS mirrors daemon events into the MPRIS properties, which
+// emits PropertiesChanged for desktop widgets.
+func (d *Daemon) followMPRIS(props *prop.Properties) {
+	set := func(name string, v any) {
+		if !reflect.DeepEqual(props.GetMust(mprisPlayer, name), v) {
+			props.SetMust(mprisPlayer, name, v)
+		}
+	}
+
+	var trackID int
+	update := func(s Status) {
+		state := "Stopped"
+		switch {
+		case s.Playing:
+			state = "Playing"
+		case s.Paused:
+			state = "Paused"
+		}
+		set("PlaybackStatus", state)
+
+		volume := s.Volume
+		if volume == 0 {
+			volume = 100
+		}
+		set("Volume", float64(volume)/100)
+
+		if !reflect.DeepEqual(props.GetMust(mprisPlayer, "Metadata"), mprisMetadata(s, trackID)) {
+			trackID++
+			set("Metadata", mprisMetadata(s, trackID))
+		}
+	}
+
+	for {
+		// resubscribe if we ever fall behind and get dropped
+		events, status := d.subscribe(false)
+		update(status)
+		for ev := range events {
+			update(ev.Status)
+		}
+	}
+}
+
+// mprisMetadata describes the playing station and track: the track (or
+// station) as the title, the station as album and artist.
+func mprisMetadata(s Status, trackID int) map[string]dbus.Variant {
+	if s.Station == "" {
+		return map[string]dbus.Variant{"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(mprisNoTrack))}
+	}
+
+	title := s.Track
+	if title == "" {
+		title = s.Desc
+	}
+	return map[string]dbus.Variant{
+		"mpris:trackid": dbus.MakeVariant(dbus.ObjectPath(fmt.Sprintf("/org/chill/track/%d", trackID))),
+		"xesam:title":   dbus.MakeVariant(title),
+		"xesam:album":   dbus.MakeVariant(s.Desc),
+		"xesam:artist":  dbus.MakeVariant([]string{s.Station}),
+	}
+}
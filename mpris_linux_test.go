@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// startBus runs a private session bus for the test and points the daemon
+// at it, skipping the test if dbus-daemon isn't installed.
+func startBus(t *testing.T) string {
+	path, err := exec.LookPath("dbus-daemon")
+	if err != nil {
+		t.Skip("dbus-daemon not installed")
+	}
+	cmd := exec.Command(path, "--session", "--nofork", "--print-address")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	addr, err := bufio.NewReader(out).ReadString('\n')
+	if err != nil {
+		t.Fatalf("reading bus address: %v", err)
+	}
+	addr = strings.TrimSpace(addr)
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", addr)
+	return addr
+}
+
+func TestMPRIS(t *testing.T) {
+	addr := startBus(t)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	d := &Daemon{}
+	d.startMPRIS()
+
+	conn, err := dbus.Connect(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	obj := conn.Object(mprisName, mprisPath)
+
+	get := func(name string) any {
+		t.Helper()
+		v, err := obj.GetProperty(name)
+		if err != nil {
+			t.Fatalf("get %s: %v", name, err)
+		}
+		return v.Value()
+	}
+	if got := get(mprisRoot + ".Identity"); got != "chill" {
+		t.Errorf("Identity = %v; want chill", got)
+	}
+	if got := get(mprisPlayer + ".PlaybackStatus"); got != "Stopped" {
+		t.Errorf("PlaybackStatus = %v; want Stopped", got)
+	}
+
+	volume := []struct {
+		name  string
+		value any
+		want  int    // session volume afterwards
+		err   string // D-Bus error expected, if any
+	}{
+		{"half", 0.5, 50, ""},
+		{"louder than mpv allows", 5.0, maxVolume, ""},
+		{"silent", 0.0, 1, ""},
+		{"wrong type", "loud", 1, prop.ErrInvalidArg.Name},
+	}
+	for _, tt := range volume {
+		t.Run("volume "+tt.name, func(t *testing.T) {
+			err := obj.SetProperty(mprisPlayer+".Volume", dbus.MakeVariant(tt.value))
+			if tt.err != "" {
+				var dbusErr dbus.Error
+				if !errors.As(err, &dbusErr) || dbusErr.Name != tt.err {
+					t.Fatalf("set Volume to %v: got %v; want %s", tt.value, err, tt.err)
+				}
+			} else if err != nil {
+				t.Fatalf("set Volume to %v: %v", tt.value, err)
+			}
+
+			d.mu.Lock()
+			got := d.session.Volume
+			d.mu.Unlock()
+			if got != tt.want {
+				t.Errorf("session volume = %d; want %d", got, tt.want)
+			}
+		})
+	}
+}
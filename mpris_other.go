@@ -0,0 +1,6 @@
+//go:build !linux
+
+package main
+
+// startMPRIS does nothing: MPRIS is a Linux desktop interface.
+func (d *Daemon) startMPRIS() {}
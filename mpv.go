@@ -0,0 +1,150 @@
+// mpv.go implements a client for mpv's JSON IPC protocol, which the daemon
+// uses to follow what mpv is playing.
+// See https://mpv.io/manual/stable/#json-ipc.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// mpvTimeout bounds how long an IPC command waits for mpv to reply.
+const mpvTimeout = 2 * time.Second
+
+// errMPVClosed is returned for commands on a connection mpv has closed.
+var errMPVClosed = errors.New("mpv connection closed")
+
+// mpvMessage is a line received from mpv: either a reply to a command
+// (RequestID and Error set) or an event (Event set).
+type mpvMessage struct {
+	Event     string          `json:"event,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	RequestID int             `json:"request_id,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// mpvConn is a connection to a running mpv's IPC server.
+type mpvConn struct {
+	conn    io.ReadWriteCloser
+	events  chan mpvMessage // events from mpv; dropped if not read promptly
+	mu      sync.Mutex      // protects nextID and pending
+	nextID  int
+	pending map[int]chan mpvMessage
+	closed  bool
+}
+
+// dialMPV connects to mpv's IPC server at path, retrying until mpv has
+// created it or timeout expires.
+func dialMPV(path string, timeout time.Duration) (*mpvConn, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := dialIPC(path)
+		if err == nil {
+			m := &mpvConn{
+				conn:    conn,
+				events:  make(chan mpvMessage, 64),
+				pending: make(map[int]chan mpvMessage),
+			}
+			go m.read()
+			return m, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, err
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// read dispatches replies to waiting commands and events to m.events until
+// the connection closes.
+func (m *mpvConn) read() {
+	sc := bufio.NewScanner(m.conn)
+	sc.Buffer(nil, 1<<20)
+	for sc.Scan() {
+		var msg mpvMessage
+		if err := json.Unmarshal(sc.Bytes(), &msg); err != nil {
+			continue
+		}
+
+		if msg.Event != "" {
+			select {
+			case m.events <- msg:
+			default:
+			}
+			continue
+		}
+
+		m.mu.Lock()
+		ch := m.pending[msg.RequestID]
+		delete(m.pending, msg.RequestID)
+		m.mu.Unlock()
+		if ch != nil {
+			ch <- msg
+		}
+	}
+
+	m.mu.Lock()
+	m.closed = true
+	for id, ch := range m.pending {
+		close(ch)
+		delete(m.pending, id)
+	}
+	m.mu.Unlock()
+	close(m.events)
+}
+
+// Events returns the channel of events from mpv. It is closed when the
+// connection ends.
+func (m *mpvConn) Events() <-chan mpvMessage {
+	return m.events
+}
+
+// Command runs an mpv IPC command, e.g. Command("set_property", "pause", true),
+// and returns its data.
+func (m *mpvConn) Command(args ...any) (json.RawMessage, error) {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, errMPVClosed
+	}
+	m.nextID++
+	id := m.nextID
+	ch := make(chan mpvMessage, 1)
+	m.pending[id] = ch
+	m.mu.Unlock()
+
+	req, err := json.Marshal(map[string]any{"command": args, "request_id": id})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := m.conn.Write(append(req, '\n')); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply, ok := <-ch:
+		if !ok {
+			return nil, errMPVClosed
+		}
+		if reply.Error != "success" {
+			return nil, errors.New("mpv: " + reply.Error)
+		}
+		return reply.Data, nil
+	case <-time.After(mpvTimeout):
+		m.mu.Lock()
+		delete(m.pending, id)
+		m.mu.Unlock()
+		return nil, errors.New("mpv: timed out")
+	}
+}
+
+// Close closes the connection.
+func (m *mpvConn) Close() error {
+	return m.conn.Close()
+}
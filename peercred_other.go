@@ -0,0 +1,14 @@
+//go:build !windows && !linux && !darwin && !freebsd
+
+package main
+
+import (
+	"net"
+	"os"
+)
+
+// peerUID can't query peer credentials on this platform, so it trusts
+// the runtime directory's permissions to keep other users out.
+func peerUID(conn *net.UnixConn) (int, error) {
+	return os.Getuid(), nil
+}
@@ -0,0 +1,153 @@
+// profile.go defines per-station playback profiles: volume, EQ preset,
+// stream format preference and extra mpv arguments.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// maxVolume is the highest volume mpv accepts by default.
+const maxVolume = 130
+
+// Profile holds mpv settings for a station. Zero fields use mpv's defaults.
+type Profile struct {
+	Volume int      `json:"volume,omitempty"`   // 1-130
+	EQ     string   `json:"eq,omitempty"`       // preset name from eqPresets
+	Format string   `json:"format,omitempty"`   // yt-dlp format selector, e.g. "bestaudio[acodec=opus]"
+	Args   []string `json:"mpv_args,omitempty"` // extra mpv arguments
+}
+
+// eqPresets maps EQ preset names to libavfilter audio filter chains.
+var eqPresets = map[string]string{
+	"flat":   "",
+	"bass":   "equalizer=f=60:t=q:w=1:g=6,equalizer=f=150:t=q:w=1:g=3",
+	"warm":   "equalizer=f=200:t=q:w=1:g=3,equalizer=f=6000:t=q:w=1:g=-3",
+	"bright": "equalizer=f=4000:t=q:w=1:g=3,equalizer=f=10000:t=q:w=1:g=4",
+	"vocal":  "equalizer=f=100:t=q:w=1:g=-3,equalizer=f=1500:t=q:w=1.5:g=4",
+	"night":  "acompressor=threshold=-20dB:ratio=4:makeup=4,equalizer=f=60:t=q:w=1:g=-4",
+}
+
+// eqNames returns the EQ preset names, sorted.
+func eqNames() []string {
+	var names []string
+	for name := range eqPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// eqLabel labels the EQ filter in mpv's filter chain, so it can be swapped
+// without touching filters set by mpv_args or mpv's own config.
+const eqLabel = "@chilleq"
+
+// eqFilter returns the labelled mpv audio filter for an EQ preset, or ""
+// for none.
+func eqFilter(preset string) string {
+	chain := eqPresets[strings.ToLower(preset)]
+	if chain == "" {
+		return ""
+	}
+	return eqLabel + ":lavfi=[" + chain + "]"
+}
+
+// Validate reports whether the profile's settings are usable.
+func (p Profile) Validate() error {
+	if p.Volume < 0 || p.Volume > maxVolume {
+		return fmt.Errorf("volume must be between 0 and %d", maxVolume)
+	}
+	if err := checkEQ(p.EQ); err != nil {
+		return err
+	}
+	return checkFormat(p.Format)
+}
+
+// Sanitize returns the profile with an out-of-range volume clamped and an
+// unknown EQ preset or malformed format dropped, and what it changed.
+func (p Profile) Sanitize() (Profile, error) {
+	var fixed []string
+	if p.Volume < 0 || p.Volume > maxVolume {
+		clamped := max(0, min(p.Volume, maxVolume))
+		fixed = append(fixed, fmt.Sprintf("volume %d is out of range, using %d", p.Volume, clamped))
+		p.Volume = clamped
+	}
+	if err := checkEQ(p.EQ); err != nil {
+		fixed = append(fixed, err.Error()+", ignored")
+		p.EQ = ""
+	}
+	if err := checkFormat(p.Format); err != nil {
+		fixed = append(fixed, err.Error()+", ignored")
+		p.Format = ""
+	}
+	if len(fixed) == 0 {
+		return p, nil
+	}
+	return p, errors.New(strings.Join(fixed, "; "))
+}
+
+func checkEQ(preset string) error {
+	if _, ok := eqPresets[strings.ToLower(preset)]; preset != "" && !ok {
+		return fmt.Errorf("unknown eq preset %q (have %s)", preset, strings.Join(eqNames(), ", "))
+	}
+	return nil
+}
+
+// checkFormat rejects format selectors yt-dlp can't parse: ones with
+// control characters, spaces outside a [filter], or unbalanced brackets.
+func checkFormat(format string) error {
+	bad := fmt.Errorf("bad format %q", format)
+	depth := 0
+	for _, r := range format {
+		switch r {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+		if depth < 0 || unicode.IsControl(r) || unicode.IsSpace(r) && depth == 0 {
+			return bad
+		}
+	}
+	if depth != 0 {
+		return bad
+	}
+	return nil
+}
+
+// Merge returns p with any fields set in o taking precedence. Extra mpv
+// arguments from both are kept.
+func (p Profile) Merge(o Profile) Profile {
+	if o.Volume != 0 {
+		p.Volume = o.Volume
+	}
+	if o.EQ != "" {
+		p.EQ = o.EQ
+	}
+	if o.Format != "" {
+		p.Format = o.Format
+	}
+	p.Args = append(append([]string(nil), p.Args...), o.Args...)
+	return p
+}
+
+// MPVArgs returns the mpv arguments that apply the profile.
+func (p Profile) MPVArgs() []string {
+	var args []string
+	if p.Volume != 0 {
+		args = append(args, fmt.Sprintf("--volume=%d", p.Volume))
+	}
+	if p.Format != "" {
+		args = append(args, "--ytdl-format="+p.Format)
+	}
+	args = append(args, p.Args...)
+	// added after mpv_args, so an --af there doesn't replace it
+	if af := eqFilter(p.EQ); af != "" {
+		args = append(args, "--af-add="+af)
+	}
+	return args
+}
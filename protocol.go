@@ -0,0 +1,163 @@
+// protocol.go defines the daemon's versioned JSON protocol. Each request and
+// response is one line of JSON on the socket. Lines that don't start with
+// '{' are legacy text commands like "play chillhop", which old clients still
+// send and which get plain text replies.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// protocolVersion is the JSON protocol version spoken by this daemon.
+const protocolVersion = 1
+
+// Error codes carried in protocol errors.
+const (
+	errBadRequest     = "bad_request"     // malformed request or arguments
+	errUnsupported    = "unsupported"     // unknown protocol version
+	errUnknownAction  = "unknown_action"  // no such action
+	errUnknownStation = "unknown_station" // station name didn't resolve
+	errNoStations     = "no_stations"     // nothing to pick from
+	errNoHistory      = "no_history"      // prev with nothing to go back to
+	errNotPlaying     = "not_playing"     // action needs something playing
+	errPlayback       = "playback_failed" // resolving or starting the stream failed
+	errInternal       = "internal"        // daemon-side failure
+	errUnauthorized   = "unauthorized"    // remote connection without a valid token
+)
+
+// ProtoError is a typed protocol error. Message is suitable for display.
+type ProtoError struct {
+	Code        string   `json:"code"`
+	Message     string   `json:"message"`
+	Suggestions []string `json:"suggestions,omitempty"` // for unknown_station
+}
+
+func (e *ProtoError) Error() string {
+	return e.Message
+}
+
+// protoError returns a ProtoError with the given code and message.
+func protoError(code, message string) *ProtoError {
+	return &ProtoError{Code: code, Message: message}
+}
+
+// Request is a JSON protocol request. Which fields apply depends on Action.
+type Request struct {
+	V       int             `json:"v"`
+	ID      json.RawMessage `json:"id,omitempty"` // echoed back in the response
+	Action  string          `json:"action"`
+	Station string          `json:"station,omitempty"` // play, rate: station name, URL or @channel
+	Title   string          `json:"title,omitempty"`   // play: display title for a URL
+	Tag     string          `json:"tag,omitempty"`     // play, skip: pick among stations with this tag
+	Rating  string          `json:"rating,omitempty"`  // rate: up, down or clear
+	Setting string          `json:"setting,omitempty"` // set: volume, eq, format or mute
+	Value   string          `json:"value,omitempty"`   // set: new value, or "default"
+	Op      string          `json:"op,omitempty"`      // queue: set, add, show or clear
+	Queue   string          `json:"queue,omitempty"`   // queue set/add: "chillhop 30m, sleep"
+	Token   string          `json:"token,omitempty"`   // auth: the remote access token
+}
+
+// Response is a JSON protocol response. On success Result holds the
+// action's structured result (see the actions in Daemon.execute) and
+// Message a human-readable summary; on failure Error is set.
+type Response struct {
+	V       int             `json:"v"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	OK      bool            `json:"ok"`
+	Message string          `json:"message,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *ProtoError     `json:"error,omitempty"`
+}
+
+// RateResult is the result of a rate request.
+type RateResult struct {
+	Station string `json:"station"`
+	Rating  int    `json:"rating"`
+}
+
+// parseLegacy converts a legacy text command into a request.
+func parseLegacy(line string) Request {
+	action, arg, _ := strings.Cut(strings.TrimSpace(line), " ")
+	arg = strings.TrimSpace(arg)
+	req := Request{V: protocolVersion, Action: action}
+
+	switch action {
+	case "play":
+		req.Station = arg
+		if tag, ok := strings.CutPrefix(arg, "tag:"); ok {
+			req.Station, req.Tag = "", tag
+		} else if isURL(arg) || strings.HasPrefix(arg, "@") {
+			req.Station, req.Title, _ = strings.Cut(arg, " ")
+		}
+	case "skip":
+		req.Tag = strings.TrimPrefix(arg, "tag:")
+	case "rate":
+		req.Rating, req.Station, _ = strings.Cut(arg, " ")
+	case "set":
+		req.Setting, req.Value, _ = strings.Cut(arg, " ")
+	case "queue":
+		req.Op, req.Queue, _ = strings.Cut(arg, " ")
+	}
+	return req
+}
+
+// decodeRequest parses a request line, which is either JSON or a legacy
+// text command. A JSON request without a version is taken to be the
+// current one.
+func decodeRequest(line string) (req Request, legacy bool, err error) {
+	if !strings.HasPrefix(line, "{") {
+		return parseLegacy(line), true, nil
+	}
+	if err := json.Unmarshal([]byte(line), &req); err != nil {
+		return req, false, protoError(errBadRequest, "bad request: "+err.Error())
+	}
+	if req.V != 0 && req.V != protocolVersion {
+		return req, false, protoError(errUnsupported, fmt.Sprintf("unsupported protocol version %d (daemon speaks %d)", req.V, protocolVersion))
+	}
+	return req, false, nil
+}
+
+// encodeResponse formats the outcome of req as a reply line. Legacy
+// commands get plain text: JSON for status, history and queue show,
+// space-separated names for list, and the message or error text otherwise.
+func encodeResponse(req Request, legacy bool, result any, msg string, err error) string {
+	if legacy {
+		switch {
+		case err != nil:
+			return err.Error()
+		case req.Action == "status", req.Action == "history", req.Action == "queue" && req.Op == "show":
+			b, _ := json.Marshal(result)
+			return string(b)
+		case req.Action == "list":
+			var names []string
+			for _, s := range result.([]Station) {
+				names = append(names, s.Name)
+			}
+			return strings.Join(names, " ")
+		}
+		return msg
+	}
+
+	resp := Response{V: protocolVersion, ID: req.ID}
+	if err != nil {
+		resp.Error = asProtoError(err)
+	} else {
+		resp.OK = true
+		resp.Message = msg
+		resp.Result, _ = json.Marshal(result)
+	}
+	b, _ := json.Marshal(resp)
+	return string(b)
+}
+
+// asProtoError converts err to a protocol error, treating untyped errors
+// as internal.
+func asProtoError(err error) *ProtoError {
+	if pe, ok := err.(*ProtoError); ok {
+		return pe
+	}
+	return protoError(errInternal, err.Error())
+}
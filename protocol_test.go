@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseLegacy(t *testing.T) {
+	tests := []struct {
+		line string
+		want Request
+	}{
+		{"play", Request{Action: "play"}},
+		{"play chillhop", Request{Action: "play", Station: "chillhop"}},
+		{"play  lofi girl ", Request{Action: "play", Station: "lofi girl"}},
+		{"play tag:focus", Request{Action: "play", Tag: "focus"}},
+		{"play https://example.com/a.mp3 My Radio", Request{Action: "play", Station: "https://example.com/a.mp3", Title: "My Radio"}},
+		{"play @SomeChannel", Request{Action: "play", Station: "@SomeChannel"}},
+		{"skip", Request{Action: "skip"}},
+		{"skip tag:sleep", Request{Action: "skip", Tag: "sleep"}},
+		{"skip sleep", Request{Action: "skip", Tag: "sleep"}},
+		{"rate up chillhop", Request{Action: "rate", Rating: "up", Station: "chillhop"}},
+		{"rate down", Request{Action: "rate", Rating: "down"}},
+		{"set volume 50", Request{Action: "set", Setting: "volume", Value: "50"}},
+		{"set format bestaudio[acodec=opus]", Request{Action: "set", Setting: "format", Value: "bestaudio[acodec=opus]"}},
+		{"queue set chillhop 30m, sleep", Request{Action: "queue", Op: "set", Queue: "chillhop 30m, sleep"}},
+		{"queue show", Request{Action: "queue", Op: "show"}},
+		{"pause", Request{Action: "pause"}},
+		{"resume", Request{Action: "resume"}},
+		{"toggle", Request{Action: "toggle"}},
+		{"stop", Request{Action: "stop"}},
+		{"next", Request{Action: "next"}},
+		{"prev", Request{Action: "prev"}},
+		{"restore", Request{Action: "restore"}},
+		{"status", Request{Action: "status"}},
+		{"list", Request{Action: "list"}},
+		{"history", Request{Action: "history"}},
+		{"subscribe", Request{Action: "subscribe"}},
+		{"shutdown", Request{Action: "shutdown"}},
+		{"quit", Request{Action: "quit"}},
+		{"dance wildly", Request{Action: "dance"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			tt.want.V = protocolVersion
+			if got := parseLegacy(tt.line); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseLegacy(%q) = %+v; want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		want   Request
+		legacy bool
+		code   string // error code, if it fails
+	}{
+		{
+			name:   "legacy",
+			line:   "play chillhop",
+			want:   Request{V: protocolVersion, Action: "play", Station: "chillhop"},
+			legacy: true,
+		},
+		{
+			name: "versioned",
+			line: `{"v": 1, "id": 7, "action": "play", "station": "chillhop"}`,
+			want: Request{V: 1, ID: json.RawMessage("7"), Action: "play", Station: "chillhop"},
+		},
+		{
+			name: "unversioned",
+			line: `{"action": "set", "setting": "volume", "value": "40"}`,
+			want: Request{Action: "set", Setting: "volume", Value: "40"},
+		},
+		{
+			name: "unknown version",
+			line: `{"v": 99, "action": "status"}`,
+			want: Request{V: 99, Action: "status"},
+			code: errUnsupported,
+		},
+		{
+			name: "malformed",
+			line: `{"v": 1, "action": }`,
+			code: errBadRequest,
+		},
+		{
+			name: "wrong field type",
+			line: `{"v": 1, "action": "play", "station": 3}`,
+			code: errBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, legacy, err := decodeRequest(tt.line)
+			if tt.code != "" {
+				if err == nil || asProtoError(err).Code != tt.code {
+					t.Fatalf("decodeRequest(%q) error = %v; want code %s", tt.line, err, tt.code)
+				}
+				if tt.want.Action != "" && !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("decodeRequest(%q) = %+v; want %+v", tt.line, got, tt.want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeRequest(%q): %v", tt.line, err)
+			}
+			if legacy != tt.legacy || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeRequest(%q) = %+v, %v; want %+v, %v", tt.line, got, legacy, tt.want, tt.legacy)
+			}
+		})
+	}
+}
+
+func TestEncodeResponse(t *testing.T) {
+	status := Status{Playing: true, Station: "chillhop", Desc: "Chillhop Radio"}
+	list := []Station{{Name: "lofi-girl"}, {Name: "chillhop"}}
+	tests := []struct {
+		name   string
+		req    Request
+		legacy bool
+		result any
+		msg    string
+		err    error
+		want   string
+	}{
+		{
+			name:   "legacy message",
+			req:    Request{Action: "pause"},
+			legacy: true,
+			result: status,
+			msg:    "paused",
+			want:   "paused",
+		},
+		{
+			name:   "legacy status",
+			req:    Request{Action: "status"},
+			legacy: true,
+			result: status,
+			want:   `{"playing":true,"paused":false,"station":"chillhop","desc":"Chillhop Radio"}`,
+		},
+		{
+			name:   "legacy list",
+			req:    Request{Action: "list"},
+			legacy: true,
+			result: list,
+			want:   "lofi-girl chillhop",
+		},
+		{
+			name:   "legacy error",
+			req:    Request{Action: "play", Station: "nope"},
+			legacy: true,
+			err:    protoError(errUnknownStation, "unknown station: nope"),
+			want:   "unknown station: nope",
+		},
+		{
+			name:   "json result",
+			req:    Request{V: 1, ID: json.RawMessage(`"a1"`), Action: "pause"},
+			result: Status{Paused: true},
+			msg:    "paused",
+			want:   `{"v":1,"id":"a1","ok":true,"message":"paused","result":{"playing":false,"paused":true}}`,
+		},
+		{
+			name: "json error",
+			req:  Request{V: 1, ID: json.RawMessage("2"), Action: "pause"},
+			err:  errNothingPlaying,
+			want: `{"v":1,"id":2,"ok":false,"error":{"code":"not_playing","message":"nothing playing"}}`,
+		},
+		{
+			name: "json internal error",
+			req:  Request{V: 1, Action: "set"},
+			err:  json.Unmarshal([]byte("{"), new(any)),
+			want: `{"v":1,"ok":false,"error":{"code":"internal","message":"unexpected end of JSON input"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := encodeResponse(tt.req, tt.legacy, tt.result, tt.msg, tt.err)
+			if got != tt.want {
+				t.Errorf("encodeResponse = %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// queue.go implements the station queue: a sequence of timed segments like
+// "chillhop 30m, code-radio 1h, sleep" that the daemon advances through.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Segment is one entry in the queue. A zero Duration plays indefinitely
+// and is only allowed for the last segment.
+type Segment struct {
+	Station  string
+	Duration time.Duration
+}
+
+// String formats the segment as it would be written in a queue spec.
+func (s Segment) String() string {
+	if s.Duration == 0 {
+		return s.Station
+	}
+	return s.Station + " " + shortDuration(s.Duration)
+}
+
+// QueueStatus describes the queue, serialized as JSON for clients.
+type QueueStatus struct {
+	Current   string   `json:"current,omitempty"`   // station of the running segment
+	Remaining string   `json:"remaining,omitempty"` // time left in the running segment
+	Next      []string `json:"next,omitempty"`      // upcoming segments
+}
+
+// parseQueue parses a spec like "chillhop 30m, code-radio 1h, sleep",
+// resolving station names the same way play does.
+func parseQueue(spec string) ([]Segment, error) {
+	var segments []Segment
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("bad queue entry %q (want \"station [duration]\")", strings.TrimSpace(part))
+		}
+
+		station, suggestions := matchStation(fields[0])
+		if station == nil {
+			return nil, errors.New(unknownStation(fields[0], suggestions))
+		}
+
+		seg := Segment{Station: station.Name}
+		if len(fields) == 2 {
+			d, err := time.ParseDuration(fields[1])
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("bad duration %q for %s", fields[1], station.Name)
+			}
+			seg.Duration = d
+		}
+		segments = append(segments, seg)
+	}
+
+	if len(segments) == 0 {
+		return nil, errors.New("empty queue")
+	}
+	for _, seg := range segments[:len(segments)-1] {
+		if seg.Duration == 0 {
+			return nil, fmt.Errorf("%s needs a duration; only the last entry can play indefinitely", seg.Station)
+		}
+	}
+	return segments, nil
+}
+
+// shortDuration formats d without zero trailing units, e.g. "1h30m" or "45m".
+func shortDuration(d time.Duration) string {
+	s := d.Round(time.Second).String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
@@ -0,0 +1,267 @@
+// remote.go implements remote control: an optional TLS listener on the
+// daemon that speaks the same protocol as the local socket once a client
+// presents the access token, and the client side of --remote.
+
+package main
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// authTimeout bounds the TLS handshake and auth exchange on a remote
+// connection.
+const authTimeout = 10 * time.Second
+
+// remoteAddr is the daemon to control, set by --remote or CHILL_REMOTE.
+// Empty means the local daemon.
+var remoteAddr string
+
+func tokenPath() string        { return filepath.Join(configDir(), "remote-token") }
+func certPath() string         { return filepath.Join(configDir(), "remote-cert.pem") }
+func keyPath() string          { return filepath.Join(configDir(), "remote-key.pem") }
+func knownRemotesPath() string { return filepath.Join(configDir(), "known_remotes.json") }
+
+// listenRemote starts the TLS listener for remote clients on addr,
+// generating the access token and certificate on first run.
+func (d *Daemon) listenRemote(addr string) error {
+	token, err := loadToken(true)
+	if err != nil {
+		return fmt.Errorf("remote token: %w", err)
+	}
+	cert, err := loadCert()
+	if err != nil {
+		return fmt.Errorf("remote certificate: %w", err)
+	}
+
+	ln, err := tls.Listen("tcp", addr, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS13,
+	})
+	if err != nil {
+		return err
+	}
+	d.remoteListener = ln
+	d.remoteFingerprint = fingerprint(cert.Certificate[0])
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go d.handleRemote(conn, token)
+		}
+	}()
+	return nil
+}
+
+// handleRemote serves a remote connection once it has authenticated.
+func (d *Daemon) handleRemote(conn net.Conn, token string) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	if err := authenticate(conn, reader, token); err != nil {
+		slog.Warn("remote client rejected", "addr", conn.RemoteAddr(), "err", err)
+		return
+	}
+	slog.Info("remote client connected", "addr", conn.RemoteAddr())
+	d.serve(conn, reader)
+}
+
+// authenticate reads a connection's first request, which must be an auth
+// request carrying the token, and answers it. Nothing else runs until it
+// succeeds.
+func authenticate(conn net.Conn, reader *bufio.Reader, token string) error {
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	req, legacy, err := decodeRequest(strings.TrimSpace(line))
+	if err == nil && (legacy || !validToken(req.Token, token)) {
+		err = protoError(errUnauthorized, "invalid token")
+	}
+	conn.Write([]byte(encodeResponse(req, false, nil, "authenticated", err) + "\n"))
+	return err
+}
+
+// validToken reports whether got is the access token, in constant time.
+func validToken(got, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+// loadToken reads the remote access token: CHILL_TOKEN if set, otherwise
+// the token file. With create, a missing token file is generated.
+func loadToken(create bool) (string, error) {
+	if v := os.Getenv("CHILL_TOKEN"); v != "" {
+		return v, nil
+	}
+	data, err := os.ReadFile(tokenPath())
+	if err == nil {
+		if token := strings.TrimSpace(string(data)); token != "" {
+			return token, nil
+		}
+	}
+	if !create {
+		if err == nil || errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("no access token; copy %s from the remote machine or set CHILL_TOKEN", tokenPath())
+		}
+		return "", err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	return token, writeFileAtomic(tokenPath(), []byte(token+"\n"), 0600)
+}
+
+// loadCert loads the daemon's TLS certificate, generating a self-signed
+// one on first run. Clients pin it rather than checking it against a CA.
+func loadCert() (tls.Certificate, error) {
+	if cert, err := tls.LoadX509KeyPair(certPath(), keyPath()); err == nil {
+		return cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	host, _ := os.Hostname()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "chill " + host},
+		DNSNames:     []string{host, "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := writeFileAtomic(keyPath(), keyPEM, 0600); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := writeFileAtomic(certPath(), certPEM, 0644); err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// fingerprint is the SHA-256 of a DER certificate, as shown to users.
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:])
+}
+
+// dialRemote connects to a remote daemon over TLS and authenticates with
+// the access token, returning a connection ready for requests.
+func dialRemote(addr string) (net.Conn, error) {
+	token, err := loadToken(false)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: authTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		MinVersion: tls.VersionTLS13,
+		// the certificate is self-signed; it's pinned by verifyPin instead
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			return verifyPin(addr, cs.PeerCertificates[0].Raw)
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := sendAuth(conn, token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// sendAuth authenticates a new connection to the daemon with token.
+func sendAuth(conn net.Conn, token string) error {
+	conn.SetDeadline(time.Now().Add(authTimeout))
+	defer conn.SetDeadline(time.Time{})
+
+	req, _ := json.Marshal(numbered(Request{Action: "auth", Token: token}))
+	if _, err := conn.Write(append(req, '\n')); err != nil {
+		return err
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		return err
+	}
+	var resp Response
+	if err := json.Unmarshal([]byte(line), &resp); err != nil {
+		return oldDaemon(strings.TrimSpace(line))
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return nil
+}
+
+// verifyPin checks a remote daemon's certificate against the one seen on
+// first connecting to addr, trusting and recording it if this is the first
+// time.
+func verifyPin(addr string, der []byte) error {
+	known := map[string]string{}
+	if data, err := os.ReadFile(knownRemotesPath()); err == nil {
+		if err := json.Unmarshal(data, &known); err != nil {
+			return fmt.Errorf("%s: %w", knownRemotesPath(), err)
+		}
+	}
+
+	fp := fingerprint(der)
+	switch known[addr] {
+	case fp:
+		return nil
+	case "":
+		fmt.Fprintf(os.Stderr, dim+"trusting %s (sha256 %s)"+reset+"\n", addr, fp)
+		known[addr] = fp
+		data, _ := json.MarshalIndent(known, "", "  ")
+		return writeFileAtomic(knownRemotesPath(), data, 0600)
+	}
+	return fmt.Errorf("certificate for %s has changed (sha256 %s); if that's expected, remove it from %s",
+		addr, fp, knownRemotesPath())
+}
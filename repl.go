@@ -14,13 +14,19 @@ import (
 // suggestions contains the available REPL commands for tab completion.
 var suggestions = []prompt.Suggest{
 	{Text: "play", Description: "play a station"},
-	{Text: "skip", Description: "skip to random station"},
+	{Text: "skip", Description: "skip to random station (optionally tag:<name>)"},
+	{Text: "next", Description: "next station in the catalog"},
+	{Text: "prev", Description: "previous station"},
 	{Text: "pause", Description: "pause playback"},
 	{Text: "resume", Description: "resume playback"},
 	{Text: "toggle", Description: "toggle play/pause"},
 	{Text: "status", Description: "show current status"},
 	{Text: "list", Description: "list all stations"},
+	{Text: "rate", Description: "rate current station up, down or clear"},
+	{Text: "set", Description: "set volume, eq, format or mute for this session"},
+	{Text: "queue", Description: "queue stations: set, add, show or clear"},
 	{Text: "stop", Description: "stop playback"},
+	{Text: "shutdown", Description: "stop playback and the daemon"},
 	{Text: "quit", Description: "exit chill"},
 }
 
@@ -33,6 +39,15 @@ func stationSuggestions() []prompt.Suggest {
 	return s
 }
 
+// tagSuggestions returns "tag:<name>" completions for every catalog tag.
+func tagSuggestions() []prompt.Suggest {
+	var s []prompt.Suggest
+	for _, t := range allTags() {
+		s = append(s, prompt.Suggest{Text: "tag:" + t, Description: "random station tagged " + t})
+	}
+	return s
+}
+
 // completer provides tab completion for REPL commands and station names.
 func completer(d prompt.Document) []prompt.Suggest {
 	text := d.TextBeforeCursor()
@@ -51,12 +66,67 @@ func completer(d prompt.Document) []prompt.Suggest {
 	// second argument completions
 	if len(words) >= 1 {
 		cmd := words[0]
-		if cmd == "play" {
-			prefix := ""
-			if len(words) > 1 {
-				prefix = words[1]
+		prefix := ""
+		if len(words) > 1 {
+			prefix = words[1]
+		}
+		switch cmd {
+		case "play":
+			return prompt.FilterHasPrefix(append(stationSuggestions(), tagSuggestions()...), prefix, true)
+		case "skip":
+			return prompt.FilterHasPrefix(tagSuggestions(), prefix, true)
+		case "set":
+			if len(words) == 2 && strings.HasSuffix(text, " ") || len(words) == 3 {
+				var values []string
+				switch words[1] {
+				case "eq":
+					values = append(eqNames(), "default")
+				case "mute":
+					values = []string{"on", "off"}
+				default:
+					return nil
+				}
+				var suggestions []prompt.Suggest
+				for _, v := range values {
+					suggestions = append(suggestions, prompt.Suggest{Text: v})
+				}
+				if len(words) == 2 {
+					return suggestions
+				}
+				return prompt.FilterHasPrefix(suggestions, words[2], true)
+			}
+			return prompt.FilterHasPrefix([]prompt.Suggest{
+				{Text: "volume", Description: "1-130, or default"},
+				{Text: "eq", Description: "EQ preset, or default"},
+				{Text: "format", Description: "yt-dlp format, or default"},
+				{Text: "mute", Description: "on or off"},
+			}, prefix, true)
+		case "queue":
+			if len(words) > 2 || strings.HasSuffix(text, " ") && len(words) == 2 {
+				last := ""
+				if !strings.HasSuffix(text, " ") {
+					last = strings.TrimLeft(words[len(words)-1], ",")
+				}
+				return prompt.FilterHasPrefix(stationSuggestions(), last, true)
+			}
+			return prompt.FilterHasPrefix([]prompt.Suggest{
+				{Text: "set", Description: "replace the queue, e.g. chillhop 30m, sleep"},
+				{Text: "add", Description: "append to the queue"},
+				{Text: "show", Description: "show the queue"},
+				{Text: "clear", Description: "clear the queue"},
+			}, prefix, true)
+		case "rate":
+			if len(words) == 2 && strings.HasSuffix(text, " ") {
+				return stationSuggestions()
 			}
-			return prompt.FilterHasPrefix(stationSuggestions(), prefix, true)
+			if len(words) == 3 {
+				return prompt.FilterHasPrefix(stationSuggestions(), words[2], true)
+			}
+			return prompt.FilterHasPrefix([]prompt.Suggest{
+				{Text: "up", Description: "play more often"},
+				{Text: "down", Description: "play less often"},
+				{Text: "clear", Description: "remove rating"},
+			}, prefix, true)
 		}
 	}
 
@@ -79,87 +149,83 @@ func executor(input string) {
 
 	switch cmd {
 	case "play":
-		if arg == "" {
-			arg = "lofi-girl"
-		}
-		if err := ensureDaemon(); err != nil {
-			fmt.Printf("%serror: %v%s\n", dim, err, reset)
-			return
-		}
-		resp, err := sendCommand("play " + arg)
-		if err != nil {
-			fmt.Printf("%serror: %v%s\n", dim, err, reset)
-			return
-		}
-		fmt.Printf("%s♪ %s%s\n", pink, resp, reset)
+		clientPlay(arg) // the daemon picks the default station, or resumes
 
 	case "skip":
-		if err := ensureDaemon(); err != nil {
-			fmt.Printf("%serror: %v%s\n", dim, err, reset)
-			return
-		}
-		resp, err := sendCommand("skip")
-		if err != nil {
-			fmt.Printf("%serror: %v%s\n", dim, err, reset)
-			return
-		}
-		fmt.Printf("%s♪ %s%s\n", pink, resp, reset)
+		clientSkip(arg)
 
 	case "pause":
 		if !isDaemonRunning() {
 			fmt.Printf("%snot running%s\n", dim, reset)
 			return
 		}
-		resp, err := sendCommand("pause")
+		resp, err := call(Request{Action: "pause"})
 		if err != nil {
-			fmt.Printf("%serror: %v%s\n", dim, err, reset)
+			fail(err)
 			return
 		}
-		fmt.Printf("%s⏸ %s%s\n", dim, resp, reset)
+		fmt.Printf("%s⏸ %s%s\n", dim, resp.Message, reset)
 
 	case "resume":
 		if !isDaemonRunning() {
 			fmt.Printf("%snot running%s\n", dim, reset)
 			return
 		}
-		resp, err := sendCommand("resume")
+		resp, err := call(Request{Action: "resume"})
 		if err != nil {
-			fmt.Printf("%serror: %v%s\n", dim, err, reset)
+			fail(err)
 			return
 		}
-		fmt.Printf("%s▶ %s%s\n", purple, resp, reset)
+		fmt.Printf("%s▶ %s%s\n", purple, resp.Message, reset)
+
+	case "next", "prev":
+		clientNavigate(cmd)
 
 	case "toggle":
 		clientToggle()
 
+	case "set":
+		if len(parts) < 3 {
+			fmt.Printf("%susage: set volume|eq|format|mute <value|default>%s\n", dim, reset)
+			return
+		}
+		clientSet(parts[1], strings.Join(parts[2:], " "))
+
+	case "queue":
+		clientQueue(parts[1:])
+
+	case "rate":
+		station := ""
+		if len(parts) > 2 {
+			station = parts[2]
+		}
+		clientRate(arg, station)
+
 	case "status":
 		clientStatus()
 
 	case "list":
 		for _, s := range stations {
-			fmt.Printf("  %s%-16s%s  %s%s%s\n", cyan, s.Name, reset, dim, s.Desc, reset)
+			fmt.Printf("  %s%-16s%s  %s%s%s%s\n", cyan, s.Name, reset, dim, s.Desc, formatTags(s.Tags), reset)
 		}
 
 	case "stop":
 		clientStop()
 
+	case "shutdown":
+		clientShutdown()
+
 	case "quit", "exit", "q":
 		fmt.Printf("%s~ stay chill ~%s\n", dim, reset)
 		os.Exit(0)
 
 	default:
-		// try as station name
-		if findStation(cmd) != nil {
-			if err := ensureDaemon(); err != nil {
-				fmt.Printf("%serror: %v%s\n", dim, err, reset)
-				return
-			}
-			resp, err := sendCommand("play " + cmd)
-			if err != nil {
-				fmt.Printf("%serror: %v%s\n", dim, err, reset)
-				return
-			}
-			fmt.Printf("%s♪ %s%s\n", pink, resp, reset)
+		// try as station name or tag:<name>
+		st, suggestions := matchStation(cmd)
+		if st != nil || strings.HasPrefix(cmd, "tag:") {
+			clientPlay(cmd)
+		} else if len(suggestions) > 0 {
+			fmt.Printf("%s%s%s\n", dim, unknownStation(cmd, suggestions), reset)
 		} else {
 			fmt.Printf("%sunknown: %s%s\n", dim, cmd, reset)
 		}
@@ -168,6 +234,7 @@ func executor(input string) {
 
 // runRepl starts the interactive REPL with go-prompt.
 func runRepl() {
+	interactive = true
 	fmt.Print(logo)
 	fmt.Printf("%s  type 'list' for stations, 'quit' to exit%s\n\n", dim, reset)
 
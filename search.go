@@ -0,0 +1,184 @@
+// search.go implements "chill search", which queries a Radio Browser-compatible
+// internet radio directory and lets the user play or save a result.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RadioStation is a station entry returned by the Radio Browser API.
+type RadioStation struct {
+	Name        string `json:"name"`
+	URL         string `json:"url"`
+	URLResolved string `json:"url_resolved"`
+	Codec       string `json:"codec"`
+	Bitrate     int    `json:"bitrate"`
+	Votes       int    `json:"votes"`
+	Tags        string `json:"tags"`
+	Country     string `json:"countrycode"`
+}
+
+// StreamURL returns the resolved stream URL, falling back to the listed one.
+func (r RadioStation) StreamURL() string {
+	if r.URLResolved != "" {
+		return r.URLResolved
+	}
+	return r.URL
+}
+
+// searchRadio queries the directory at base for stations matching name and tag.
+func searchRadio(base, name, tag string, limit int) ([]RadioStation, error) {
+	q := url.Values{}
+	if name != "" {
+		q.Set("name", name)
+	}
+	if tag != "" {
+		q.Set("tag", tag)
+	}
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("hidebroken", "true")
+	q.Set("order", "votes")
+	q.Set("reverse", "true")
+
+	req, err := http.NewRequest("GET", strings.TrimRight(base, "/")+"/json/stations/search?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "chill")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("radio directory: %s", resp.Status)
+	}
+
+	var results []RadioStation
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		return nil, fmt.Errorf("radio directory: %v", err)
+	}
+	return results, nil
+}
+
+// runSearch implements "chill search <query> [--tag t]".
+func runSearch(args []string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	tag := fs.String("tag", "", "only stations with this tag")
+	limit := fs.Int("limit", 15, "maximum number of results")
+	api := fs.String("api", cfg.RadioAPI, "Radio Browser-compatible API base URL")
+	play := fs.Int("play", 0, "play result number n without prompting")
+	save := fs.Int("save", 0, "save result number n without prompting")
+	query := strings.Join(parseInterspersed(fs, args), " ")
+
+	if query == "" && *tag == "" {
+		fmt.Fprintln(os.Stderr, "usage: chill search <query> [--tag tag]")
+		os.Exit(2)
+	}
+
+	results, err := searchRadio(*api, query, *tag, *limit)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(results) == 0 {
+		fmt.Println(dim + "no stations found" + reset)
+		return
+	}
+
+	for i, r := range results {
+		format := strings.ToLower(r.Codec)
+		if r.Bitrate > 0 {
+			format += fmt.Sprintf(" %dk", r.Bitrate)
+		}
+		fmt.Printf("  %s%2d%s  %s%-36s%s  %s%-9s ♥ %d%s\n",
+			purple, i+1, reset, cyan, truncate(r.Name, 36), reset, dim, format, r.Votes, reset)
+		if r.Tags != "" {
+			fmt.Printf("      %s%s%s\n", dim, truncate(r.Tags, 60), reset)
+		}
+	}
+	fmt.Println()
+
+	idx, saving := *play-1, false
+	if *save > 0 {
+		idx, saving = *save-1, true
+	}
+	if *play == 0 && *save == 0 {
+		var ok bool
+		if idx, saving, ok = pickResult(len(results)); !ok {
+			return
+		}
+	}
+	if idx < 0 || idx >= len(results) {
+		fmt.Fprintf(os.Stderr, "no result %d\n", idx+1)
+		os.Exit(1)
+	}
+
+	r := results[idx]
+	if saving {
+		saveResult(Station{URL: r.StreamURL(), Desc: strings.TrimSpace(r.Name)}, r.Name)
+		return
+	}
+	clientPlayURL(r.StreamURL(), strings.TrimSpace(r.Name))
+}
+
+// pickResult prompts the user to play or save one of n numbered results.
+// Input "3" plays result 3 and "s3" saves it; empty input cancels.
+func pickResult(n int) (idx int, save bool, ok bool) {
+	fmt.Printf("%s  play [1-%d]  save [s1-s%d]  enter to cancel ›%s ", dim, n, n, reset)
+
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	line = strings.ToLower(strings.TrimSpace(line))
+	if line == "" {
+		return 0, false, false
+	}
+
+	if strings.HasPrefix(line, "s") {
+		save = true
+		line = strings.TrimSpace(line[1:])
+	}
+
+	i, err := strconv.Atoi(line)
+	if err != nil || i < 1 || i > n {
+		fmt.Printf("%snot a result: %s%s\n", dim, line, reset)
+		return 0, false, false
+	}
+	return i - 1, save, true
+}
+
+// saveResult adds a search result to the catalog under a name generated from hint.
+func saveResult(s Station, hint string) {
+	s.Name = stationSlug(hint)
+	if err := saveStation(s); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s+ saved as %s%s%s\n", dim, cyan, s.Name, reset)
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
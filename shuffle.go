@@ -0,0 +1,148 @@
+// shuffle.go implements smart shuffle for skip. It avoids recently played
+// stations and weights the rest by the user's thumbs up/down ratings.
+// Ratings and play history are persisted in the state directory.
+
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	recentWindow = 3  // how many recently played stations skip avoids
+	historySize  = 50 // how many plays are kept in history
+
+	weightUp   = 3.0  // pick weight of a thumbs-up station
+	weightDown = 0.25 // pick weight of a thumbs-down station
+)
+
+// Play is one entry in the play history.
+type Play struct {
+	Station string    `json:"station"`
+	At      time.Time `json:"at"`
+}
+
+// Shuffle holds the persisted ratings and play history used by skip.
+type Shuffle struct {
+	Ratings map[string]int `json:"ratings,omitempty"` // station name -> 1 (up) or -1 (down)
+	History []Play         `json:"history,omitempty"` // oldest first
+}
+
+// shufflePath returns the path to the persisted shuffle state.
+func shufflePath() string {
+	return filepath.Join(stateDir(), "shuffle.json")
+}
+
+// loadShuffle reads the persisted shuffle state. A missing or unreadable
+// file yields an empty state.
+func loadShuffle() *Shuffle {
+	s := &Shuffle{}
+	if data, err := os.ReadFile(shufflePath()); err == nil {
+		json.Unmarshal(data, s)
+	}
+	if s.Ratings == nil {
+		s.Ratings = make(map[string]int)
+	}
+	return s
+}
+
+// save persists the shuffle state.
+func (s *Shuffle) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(shufflePath(), append(data, '\n'), 0644)
+}
+
+// Record adds a play of the named station to the history.
+func (s *Shuffle) Record(name string) {
+	s.History = append(s.History, Play{Station: name, At: time.Now()})
+	if len(s.History) > historySize {
+		s.History = s.History[len(s.History)-historySize:]
+	}
+	s.save()
+}
+
+// Rate sets the rating for the named station: 1 for up, -1 for down, 0 to clear.
+func (s *Shuffle) Rate(name string, rating int) error {
+	key := strings.ToLower(name)
+	if rating == 0 {
+		delete(s.Ratings, key)
+	} else {
+		s.Ratings[key] = rating
+	}
+	return s.save()
+}
+
+// Rating returns the rating for the named station.
+func (s *Shuffle) Rating(name string) int {
+	return s.Ratings[strings.ToLower(name)]
+}
+
+// recent returns the names of the last n distinct stations played.
+func (s *Shuffle) recent(n int) map[string]bool {
+	seen := make(map[string]bool)
+	for i := len(s.History) - 1; i >= 0 && len(seen) < n; i-- {
+		seen[strings.ToLower(s.History[i].Station)] = true
+	}
+	return seen
+}
+
+// Pick chooses a station from candidates other than current. Stations among
+// the last few played are avoided when possible, and the rest are chosen
+// with probability weighted by rating.
+func (s *Shuffle) Pick(candidates []Station, current string) *Station {
+	if len(candidates) == 0 {
+		return nil
+	}
+	if len(candidates) == 1 {
+		return &candidates[0]
+	}
+
+	recent := s.recent(min(recentWindow, len(candidates)-1))
+	recent[strings.ToLower(current)] = true
+
+	var pool []*Station
+	for i := range candidates {
+		if !recent[strings.ToLower(candidates[i].Name)] {
+			pool = append(pool, &candidates[i])
+		}
+	}
+	if len(pool) == 0 {
+		// everything was played recently; only avoid the current station
+		for i := range candidates {
+			if !strings.EqualFold(candidates[i].Name, current) {
+				pool = append(pool, &candidates[i])
+			}
+		}
+	}
+
+	weights := make([]float64, len(pool))
+	total := 0.0
+	for i, st := range pool {
+		switch s.Rating(st.Name) {
+		case 1:
+			weights[i] = weightUp
+		case -1:
+			weights[i] = weightDown
+		default:
+			weights[i] = 1
+		}
+		total += weights[i]
+	}
+
+	r := rand.Float64() * total
+	for i, w := range weights {
+		if r < w {
+			return pool[i]
+		}
+		r -= w
+	}
+	return pool[len(pool)-1]
+}
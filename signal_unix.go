@@ -16,3 +16,7 @@ func pauseProcess(p *os.Process) error {
 func resumeProcess(p *os.Process) error {
 	return p.Signal(syscall.SIGCONT)
 }
+
+// shutdownSignals are the signals that shut the daemon down gracefully:
+// kill, ctrl+c, and the terminal or session going away.
+var shutdownSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP}
@@ -4,6 +4,7 @@ package main
 
 import (
 	"os"
+	"syscall"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -107,3 +108,8 @@ func resumeThread(threadID uint32) error {
 	}
 	return nil
 }
+
+// shutdownSignals are the signals that shut the daemon down gracefully:
+// ctrl+c, and closing the console, logoff or shutdown (which Go delivers
+// as SIGTERM).
+var shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
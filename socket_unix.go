@@ -3,23 +3,84 @@
 package main
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
+	"syscall"
 )
 
+// runtimeDir returns the private directory holding the daemon's sockets:
+// $XDG_RUNTIME_DIR if set, otherwise a per-user directory in the temp dir.
+func runtimeDir() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("chill-%d", os.Getuid()))
+}
+
+// secureRuntimeDir creates the runtime directory if needed and makes sure
+// it is a real directory that only the current user can enter.
+func secureRuntimeDir() error {
+	dir := runtimeDir()
+	if err := os.Mkdir(dir, 0700); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	fi, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !fi.IsDir() || !ok || int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is not a directory owned by you", dir)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return os.Chmod(dir, 0700)
+	}
+	return nil
+}
+
 // socketPath returns the path to the Unix socket used for IPC.
-// The socket is user-specific to allow multiple users on the same system.
 func socketPath() string {
-	return filepath.Join(os.TempDir(), fmt.Sprintf("chill-%d.sock", os.Getuid()))
+	return socketFile(daemonProfile)
+}
+
+// socketFile returns the path of a daemon profile's socket.
+func socketFile(profile string) string {
+	return filepath.Join(runtimeDir(), socketName(profile, "sock"))
 }
 
-// listenSocket creates a Unix socket listener.
+// listenSocket creates the daemon's Unix socket, readable only by the
+// current user. A socket left behind by a daemon that died is replaced;
+// one that a live daemon still answers on is not.
 func listenSocket() (net.Listener, error) {
+	if err := secureRuntimeDir(); err != nil {
+		return nil, err
+	}
+
 	sock := socketPath()
-	os.Remove(sock) // clean up old socket
-	return net.Listen("unix", sock)
+	if _, err := os.Lstat(sock); err == nil {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("a daemon is already listening on %s", sock)
+		}
+		os.Remove(sock)
+	}
+
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(sock, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
 }
 
 // dialSocket connects to the daemon's Unix socket.
@@ -27,7 +88,35 @@ func dialSocket() (net.Conn, error) {
 	return net.Dial("unix", socketPath())
 }
 
+// checkPeer rejects Unix socket connections from other users. Other
+// connections are checked by their listeners.
+func checkPeer(conn net.Conn, reader *bufio.Reader) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil
+	}
+	uid, err := peerUID(uc)
+	if err != nil {
+		return fmt.Errorf("peer credentials: %w", err)
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("connection from uid %d refused", uid)
+	}
+	return nil
+}
+
 // cleanupSocket removes the Unix socket file.
 func cleanupSocket() {
 	os.Remove(socketPath())
 }
+
+// mpvSocketPath returns the path of the IPC socket for the daemon's mpv.
+// It lives in the runtime directory since mpv's IPC accepts any command.
+func mpvSocketPath() string {
+	return filepath.Join(runtimeDir(), fmt.Sprintf("chill-mpv-%d.sock", os.Getpid()))
+}
+
+// dialIPC connects to mpv's IPC server.
+func dialIPC(path string) (io.ReadWriteCloser, error) {
+	return net.Dial("unix", path)
+}
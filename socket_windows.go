@@ -3,20 +3,55 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
+
+	"golang.org/x/sys/windows"
 )
 
+// runtimeDir returns the directory holding the daemon's port and lock files.
+func runtimeDir() string {
+	return os.TempDir()
+}
+
+// secureRuntimeDir does nothing: the temp directory is already per-user.
+func secureRuntimeDir() error {
+	return nil
+}
+
 // socketPath returns the path to a lock file used to store the port number.
 // On Windows, we use TCP on localhost instead of Unix sockets.
 func socketPath() string {
-	return filepath.Join(os.TempDir(), "chill.port")
+	return socketFile(daemonProfile)
 }
 
+// socketFile returns the path of a daemon profile's port file.
+func socketFile(profile string) string {
+	return filepath.Join(runtimeDir(), socketName(profile, "port"))
+}
+
+// socketToken is the access token clients of the daemon's port must
+// present, since any local user can connect to it.
+var socketToken string
+
 // listenSocket creates a TCP listener on localhost.
-// The port is written to a file so clients can find it.
+// The port is written to a file so clients can find it, unless a live
+// daemon still answers on the port already there.
 func listenSocket() (net.Listener, error) {
+	if conn, err := dialPort(); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("a daemon is already listening on %s", conn.RemoteAddr())
+	}
+	token, err := loadToken(true)
+	if err != nil {
+		return nil, fmt.Errorf("access token: %w", err)
+	}
+	socketToken = token
+
 	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
 		return nil, err
@@ -31,8 +66,26 @@ func listenSocket() (net.Listener, error) {
 	return ln, nil
 }
 
-// dialSocket connects to the daemon's TCP socket.
+// dialSocket connects to the daemon's TCP socket and authenticates with
+// the access token.
 func dialSocket() (net.Conn, error) {
+	conn, err := dialPort()
+	if err != nil {
+		return nil, err
+	}
+	token, err := loadToken(false)
+	if err == nil {
+		err = sendAuth(conn, token)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// dialPort connects to the port in the daemon's port file.
+func dialPort() (net.Conn, error) {
 	data, err := os.ReadFile(socketPath())
 	if err != nil {
 		return nil, err
@@ -40,7 +93,38 @@ func dialSocket() (net.Conn, error) {
 	return net.Dial("tcp", string(data))
 }
 
+// checkPeer requires the access token from connections to the daemon's
+// port: unlike a Unix socket, any local user can reach it. Remote
+// connections are checked by their listener.
+func checkPeer(conn net.Conn, reader *bufio.Reader) error {
+	if _, ok := conn.(*net.TCPConn); !ok {
+		return nil
+	}
+	return authenticate(conn, reader, socketToken)
+}
+
 // cleanupSocket removes the port file.
 func cleanupSocket() {
 	os.Remove(socketPath())
 }
+
+// mpvSocketPath returns the named pipe used for the daemon's mpv IPC.
+func mpvSocketPath() string {
+	return fmt.Sprintf(`\\.\pipe\chill-mpv-%d`, os.Getpid())
+}
+
+// dialIPC connects to mpv's IPC named pipe. The handle is opened for
+// overlapped I/O so reads and writes can proceed concurrently.
+func dialIPC(path string) (io.ReadWriteCloser, error) {
+	name, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	h, err := windows.CreateFile(name,
+		windows.GENERIC_READ|windows.GENERIC_WRITE,
+		0, nil, windows.OPEN_EXISTING, windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return nil, err
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
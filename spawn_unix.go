@@ -0,0 +1,22 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach makes the daemon started by cmd the leader of a new session, with
+// no controlling terminal, so closing the terminal doesn't take it down.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// passReadyPipe arranges for the daemon started by cmd to inherit w, and
+// returns the descriptor it will have there.
+func passReadyPipe(cmd *exec.Cmd, w *os.File) (string, error) {
+	cmd.ExtraFiles = []*os.File{w}
+	return "3", nil // after stdin, stdout and stderr
+}
@@ -0,0 +1,139 @@
+// startup.go implements race-free daemon startup. A lock file makes sure
+// only one daemon per profile owns the socket, and a pipe inherited by the
+// spawned daemon tells the client when it is listening, or why it failed.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readyEnv names the inherited readiness pipe in the spawned daemon's
+// environment.
+const readyEnv = "CHILL_READY_FD"
+
+// readyMsg is written to the readiness pipe once the daemon is listening;
+// anything else is its startup error.
+const readyMsg = "ready"
+
+// startTimeout bounds how long a client waits for a spawned daemon.
+const startTimeout = 15 * time.Second
+
+// errLocked is returned by lockFile when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// daemonLockPath returns the lock file held by the profile's daemon for
+// as long as it runs.
+func daemonLockPath() string {
+	return filepath.Join(runtimeDir(), socketName(daemonProfile, "lock"))
+}
+
+// startLockPath returns the lock file clients hold while starting the
+// profile's daemon, so concurrent commands don't each spawn one.
+func startLockPath() string {
+	return filepath.Join(runtimeDir(), socketName(daemonProfile, "start"))
+}
+
+// lockDaemon takes the daemon lock, failing if another daemon holds it.
+func lockDaemon() (*os.File, error) {
+	if err := secureRuntimeDir(); err != nil {
+		return nil, err
+	}
+	f, err := lockFile(daemonLockPath(), false)
+	if errors.Is(err, errLocked) {
+		return nil, fmt.Errorf("a daemon is already running for profile %s", profileLabel())
+	}
+	return f, err
+}
+
+// waitForExit waits a little for the local daemon to release its lock,
+// so a command run right after shutdown starts a fresh daemon rather than
+// reaching the exiting one.
+func waitForExit() {
+	for i := 0; i < 100; i++ {
+		if f, err := lockFile(daemonLockPath(), false); err == nil {
+			f.Close()
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// startDaemon spawns the daemon, detached from the terminal with its
+// stderr (and so any crash) going to its log, and waits until it reports
+// that it is listening, returning its startup error if it fails.
+func startDaemon() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	exe, _ := os.Executable()
+	cmd := exec.Command(exe, "--daemon", "--profile", profileLabel())
+	detach(cmd)
+	if err := os.MkdirAll(stateDir(), 0755); err == nil {
+		if f, err := os.OpenFile(logPath(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600); err == nil {
+			defer f.Close()
+			cmd.Stderr = f
+		}
+	}
+	fd, err := passReadyPipe(cmd, w)
+	if err != nil {
+		w.Close()
+		return err
+	}
+	cmd.Env = append(os.Environ(), readyEnv+"="+fd)
+
+	err = cmd.Start()
+	w.Close() // the daemon has its own copy now
+	if err != nil {
+		return err
+	}
+	cmd.Process.Release()
+
+	reply := make(chan string, 1)
+	go func() {
+		b, _ := io.ReadAll(r)
+		reply <- strings.TrimSpace(string(b))
+	}()
+
+	select {
+	case msg := <-reply:
+		switch msg {
+		case readyMsg:
+			return nil
+		case "":
+			return errors.New("daemon exited during startup")
+		}
+		return errors.New("daemon failed to start: " + msg)
+	case <-time.After(startTimeout):
+		return fmt.Errorf("daemon didn't start within %v", startTimeout)
+	}
+}
+
+// signalReady reports the daemon's startup result to the client that
+// spawned it, if any: readyMsg on success, otherwise the error.
+func signalReady(startErr error) {
+	fd, err := strconv.ParseUint(os.Getenv(readyEnv), 10, 64)
+	if err != nil {
+		return
+	}
+	os.Unsetenv(readyEnv) // not for mpv
+
+	f := os.NewFile(uintptr(fd), "ready")
+	if startErr != nil {
+		fmt.Fprintln(f, startErr)
+	} else {
+		fmt.Fprintln(f, readyMsg)
+	}
+	f.Close()
+}
@@ -0,0 +1,99 @@
+// state.go persists the daemon's playback state: the last station, the
+// session's volume, EQ, format and mute, and whether playback was paused, so
+// a restarted daemon can pick up where the last one left off.
+
+package main
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// PlaybackState is the playback state saved between daemon runs.
+type PlaybackState struct {
+	Station string  `json:"station,omitempty"` // station name, URL or @channel
+	Title   string  `json:"title,omitempty"`   // display title for a URL or @channel
+	Paused  bool    `json:"paused,omitempty"`  // whether the station was paused
+	Muted   bool    `json:"muted,omitempty"`   // whether audio was muted
+	Session Profile `json:"session,omitzero"`  // volume, EQ and format set with "set"
+}
+
+// statePath returns the path to the playback state file. Each daemon
+// profile has its own.
+func statePath() string {
+	return filepath.Join(stateDir(), "state.json")
+}
+
+// loadState reads the saved playback state. A missing or unreadable file
+// gives the zero state.
+func loadState() PlaybackState {
+	var s PlaybackState
+	data, err := os.ReadFile(statePath())
+	if err != nil {
+		return s
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		slog.Warn("ignoring bad state file", "path", statePath(), "err", err)
+		return PlaybackState{}
+	}
+	return s
+}
+
+// saveState writes the playback state if it changed since it was last
+// saved. After a stop the last station is kept, so it can be resumed.
+// Called with d.mu held.
+func (d *Daemon) saveState() {
+	s := d.last
+	if d.station != nil {
+		s.Station, s.Title = stationTarget(d.station)
+	}
+	s.Paused = d.paused
+	s.Muted = d.muted
+	s.Session = d.session
+	if reflect.DeepEqual(s, d.last) {
+		return
+	}
+
+	d.last = s
+	data, _ := json.MarshalIndent(s, "", "  ")
+	if err := writeFileAtomic(statePath(), append(data, '\n'), 0644); err != nil {
+		slog.Warn("saving state failed", "err", err)
+	}
+}
+
+// stationTarget returns what to play to get station back: its name, or
+// for an uncatalogued station its URL or @channel and title.
+func stationTarget(station *Station) (target, title string) {
+	switch {
+	case station.Name == "url":
+		return station.URL, station.Desc
+	case strings.HasPrefix(station.Name, "@"):
+		return station.Name, station.Desc
+	}
+	return station.Name, ""
+}
+
+// restore resumes the last station, paused again if it was paused. If a
+// station is already loaded it just makes sure it's playing.
+func (d *Daemon) restore() (string, error) {
+	if d.station != nil {
+		if d.paused {
+			return d.resume()
+		}
+		return "playing: " + d.station.Desc, nil
+	}
+	if d.last.Station == "" {
+		return "", protoError(errNoHistory, "nothing to resume")
+	}
+
+	paused := d.last.Paused
+	msg, err := d.play(d.last.Station, d.last.Title)
+	if err != nil || !paused {
+		return msg, err
+	}
+	return d.pause()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestStationTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		station *Station
+		target  string
+		title   string
+	}{
+		{"catalog", &Station{Name: "chillhop", Channel: "@ChillhopMusic", Desc: "Chillhop Radio"}, "chillhop", ""},
+		{"url", adhocStation("https://example.com/a.mp3", ""), "https://example.com/a.mp3", "https://example.com/a.mp3"},
+		{"url with title", adhocStation("https://example.com/a.mp3", "My Radio"), "https://example.com/a.mp3", "My Radio"},
+		{"channel", adhocStation("@SomeChannel", ""), "@SomeChannel", "@SomeChannel"},
+		{"channel with title", adhocStation("@SomeChannel", "Some Radio"), "@SomeChannel", "Some Radio"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, title := stationTarget(tt.station)
+			if target != tt.target || title != tt.title {
+				t.Fatalf("stationTarget = %q, %q; want %q, %q", target, title, tt.target, tt.title)
+			}
+			if tt.title == "" {
+				return
+			}
+			// uncatalogued stations come back as they were
+			if got := adhocStation(target, title); !reflect.DeepEqual(got, tt.station) {
+				t.Errorf("adhocStation(%q, %q) = %+v; want %+v", target, title, got, tt.station)
+			}
+		})
+	}
+}
+
+func TestSaveLoadState(t *testing.T) {
+	tests := []struct {
+		name    string
+		station *Station
+		paused  bool
+		muted   bool
+		session Profile
+		last    PlaybackState // saved before, kept after a stop
+		want    PlaybackState
+	}{
+		{
+			name: "nothing played",
+		},
+		{
+			name:    "catalog station",
+			station: &Station{Name: "chillhop", Desc: "Chillhop Radio"},
+			want:    PlaybackState{Station: "chillhop"},
+		},
+		{
+			name:    "paused url with title",
+			station: adhocStation("https://example.com/a.mp3", "My Radio"),
+			paused:  true,
+			want:    PlaybackState{Station: "https://example.com/a.mp3", Title: "My Radio", Paused: true},
+		},
+		{
+			name:    "channel with session settings",
+			station: adhocStation("@SomeChannel", ""),
+			muted:   true,
+			session: Profile{Volume: 60, EQ: "bass", Format: "bestaudio"},
+			want: PlaybackState{
+				Station: "@SomeChannel",
+				Title:   "@SomeChannel",
+				Muted:   true,
+				Session: Profile{Volume: 60, EQ: "bass", Format: "bestaudio"},
+			},
+		},
+		{
+			name:    "stopped keeps the last station",
+			session: Profile{Volume: 40},
+			last:    PlaybackState{Station: "chillhop", Paused: true},
+			want:    PlaybackState{Station: "chillhop", Session: Profile{Volume: 40}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+			d := &Daemon{station: tt.station, paused: tt.paused, muted: tt.muted, session: tt.session, last: tt.last}
+			d.saveState()
+			if !reflect.DeepEqual(d.last, tt.want) {
+				t.Errorf("d.last = %+v; want %+v", d.last, tt.want)
+			}
+
+			got := loadState()
+			if reflect.DeepEqual(tt.want, PlaybackState{}) {
+				// unchanged state isn't written
+				if _, err := os.Stat(statePath()); !os.IsNotExist(err) {
+					t.Errorf("state file written for the zero state: %v", err)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadState() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStateBadFile(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := writeFileAtomic(statePath(), []byte("{not json"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := loadState(); !reflect.DeepEqual(got, PlaybackState{}) {
+		t.Errorf("loadState() = %+v; want the zero state", got)
+	}
+}
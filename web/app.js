@@ -0,0 +1,164 @@
+// app.js drives the chill dashboard: it renders the daemon's status, station
+// list and track history, sends transport commands to the HTTP API, and
+// follows daemon events over server-sent events.
+
+const $ = (id) => document.getElementById(id);
+
+let status = {};
+let stations = [];
+let history = [];
+
+// The API needs the access token. Open the dashboard once as
+// /#token=<token>; it's kept for this origin and dropped from the address.
+const hashToken = new URLSearchParams(location.hash.slice(1)).get("token");
+if (hashToken) {
+  localStorage.setItem("token", hashToken);
+  window.history.replaceState(null, "", location.pathname);
+}
+const token = localStorage.getItem("token") || "";
+const auth = { Authorization: "Bearer " + token };
+
+const needToken = "unauthorized: open this page as /#token=<access token> (remote-token in chill's config directory)";
+
+// post sends an action to the daemon, showing any error it returns.
+async function post(action, body) {
+  $("error").textContent = "";
+  try {
+    const res = await fetch("/" + action, {
+      method: "POST",
+      headers: { ...auth, "Content-Type": "application/json" },
+      body: JSON.stringify(body || {}),
+    });
+    const resp = await res.json();
+    if (!resp.ok) {
+      $("error").textContent = res.status === 401 ? needToken : resp.error.message;
+    }
+  } catch (err) {
+    $("error").textContent = String(err);
+  }
+}
+
+async function get(path) {
+  const res = await fetch(path, { headers: auth });
+  if (res.status === 401) {
+    $("error").textContent = needToken;
+  }
+  const resp = await res.json();
+  return resp.ok ? resp.result : null;
+}
+
+function el(tag, className, text) {
+  const e = document.createElement(tag);
+  if (className) e.className = className;
+  if (text) e.textContent = text;
+  return e;
+}
+
+function clock(time) {
+  return new Date(time).toLocaleTimeString([], { hour: "2-digit", minute: "2-digit" });
+}
+
+function renderStatus() {
+  const s = status;
+  const active = s.playing || s.paused;
+
+  $("state").textContent = s.paused
+    ? "⏸ paused"
+    : s.playing
+      ? "▶ playing"
+      : s.reconnecting
+        ? "reconnecting to " + s.reconnecting
+        : "idle";
+  $("desc").textContent = active ? s.desc : "";
+  $("track").textContent = s.track && s.track !== s.desc ? "♫ " + s.track : "";
+
+  const info = [];
+  if (active) {
+    info.push(s.station);
+    if (s.tag) info.push("#" + s.tag);
+    if (s.eq) info.push("eq " + s.eq);
+    if (s.muted) info.push("muted");
+    if (s.rating === 1) info.push("↑");
+    if (s.rating === -1) info.push("↓");
+    if (s.queue && s.queue.next) info.push("next: " + s.queue.next[0]);
+  }
+  $("info").textContent = info.join(" │ ");
+
+  if (document.activeElement !== $("volume")) {
+    $("volume").value = s.volume || 100;
+    $("volume-value").textContent = s.volume || 100;
+  }
+
+  for (const li of $("stations").children) {
+    li.classList.toggle("playing", active && li.dataset.name === s.station);
+  }
+}
+
+function renderStations() {
+  const list = $("stations");
+  list.replaceChildren();
+  for (const st of stations) {
+    const li = el("li");
+    li.dataset.name = st.name;
+    li.append(el("span", "name", st.name), " ", el("span", "dim", st.desc));
+    if (st.tags) {
+      li.append(" ", el("span", "tags", st.tags.map((t) => "#" + t).join(" ")));
+    }
+    li.onclick = () => post("play", { station: st.name });
+    list.append(li);
+  }
+  renderStatus();
+}
+
+function renderHistory() {
+  const list = $("history");
+  list.replaceChildren();
+  if (history.length === 0) {
+    list.append(el("li", "dim", "nothing yet"));
+  }
+  for (const t of history) {
+    const li = el("li");
+    li.append(el("span", "when", clock(t.at)), t.title, " ", el("span", "tags", t.station));
+    list.append(li);
+  }
+}
+
+function connect() {
+  const source = new EventSource("/events?token=" + encodeURIComponent(token));
+  source.onopen = () => {
+    $("conn").textContent = "live";
+  };
+  source.onerror = () => {
+    $("conn").textContent = "reconnecting…";
+  };
+  source.onmessage = (msg) => {
+    const ev = JSON.parse(msg.data);
+    status = ev.status;
+    if (ev.event === "track" && ev.message) {
+      history.unshift({ title: ev.message, station: ev.status.station, at: ev.time });
+      history = history.slice(0, 50);
+      renderHistory();
+    }
+    if (ev.event === "error") {
+      $("error").textContent = ev.message;
+    }
+    renderStatus();
+  };
+}
+
+for (const button of document.querySelectorAll("[data-action]")) {
+  button.onclick = () => post(button.dataset.action);
+}
+
+$("volume").oninput = () => {
+  $("volume-value").textContent = $("volume").value;
+};
+$("volume").onchange = () => post("volume", { volume: Number($("volume").value) });
+
+(async () => {
+  stations = (await get("/stations")) || [];
+  history = (await get("/history")) || [];
+  renderStations();
+  renderHistory();
+  connect();
+})();
@@ -0,0 +1,86 @@
+// ytdlp.go wraps the yt-dlp command used to look up YouTube streams.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ytdlp runs yt-dlp with args and returns its stdout. On failure the error
+// carries yt-dlp's last stderr line, which usually explains what went wrong.
+func ytdlp(ctx context.Context, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "yt-dlp", args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		if msg := strings.TrimSpace(lines[len(lines)-1]); msg != "" {
+			return stdout.Bytes(), errors.New(strings.TrimPrefix(msg, "ERROR: "))
+		}
+		return stdout.Bytes(), err
+	}
+	return stdout.Bytes(), nil
+}
+
+// resolveTimeout bounds how long resolving a channel's live stream may take.
+const resolveTimeout = 20 * time.Second
+
+// channelURL returns the streams tab URL for a channel given as an @handle,
+// a UC… channel ID, or a full channel URL.
+func channelURL(channel string) string {
+	switch {
+	case strings.HasPrefix(channel, "http://"), strings.HasPrefix(channel, "https://"):
+		return strings.TrimSuffix(strings.TrimSuffix(channel, "/"), "/streams") + "/streams"
+	case strings.HasPrefix(channel, "UC"):
+		return "https://www.youtube.com/channel/" + channel + "/streams"
+	default:
+		return "https://www.youtube.com/@" + strings.TrimPrefix(channel, "@") + "/streams"
+	}
+}
+
+// resolveLive returns the watch URL of the channel's current live stream.
+// If match is set, the first live stream whose title contains it
+// (case-insensitive) is chosen.
+func resolveLive(ctx context.Context, channel, match string) (string, error) {
+	out, err := ytdlp(ctx,
+		"--flat-playlist",
+		"--dump-json",
+		"--playlist-end", "30",
+		"--match-filter", "live_status=is_live",
+		channelURL(channel),
+	)
+	if err != nil {
+		return "", err
+	}
+
+	match = strings.ToLower(match)
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		var l LiveStream
+		if err := json.Unmarshal(line, &l); err != nil || l.ID == "" {
+			continue
+		}
+		if l.Status != "" && l.Status != "is_live" {
+			continue
+		}
+		if match == "" || strings.Contains(strings.ToLower(l.Title), match) {
+			return l.URL(), nil
+		}
+	}
+
+	if match != "" {
+		return "", fmt.Errorf("no live stream matching %q", match)
+	}
+	return "", errors.New("channel is not live")
+}